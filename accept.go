@@ -0,0 +1,78 @@
+package powermux
+
+import (
+	"strconv"
+	"strings"
+)
+
+// qualityValue is a single entry of a header with quality values, such as Accept or Accept-Encoding
+type qualityValue struct {
+	value  string
+	params map[string]string
+	q      float64
+}
+
+// parseQualityList parses a comma separated list of values with optional parameters and quality values.
+// Values are lower cased. Entries with an unparsable quality are given a quality of 0.
+func parseQualityList(header string) []qualityValue {
+	values := make([]qualityValue, 0, 4)
+
+	for _, entry := range strings.Split(header, ",") {
+		parts := strings.Split(entry, ";")
+
+		qv := qualityValue{
+			value: strings.ToLower(strings.TrimSpace(parts[0])),
+			q:     1,
+		}
+		if qv.value == "" {
+			continue
+		}
+
+		for _, param := range parts[1:] {
+			key, value, _ := strings.Cut(param, "=")
+			key = strings.ToLower(strings.TrimSpace(key))
+			value = strings.Trim(strings.TrimSpace(value), `"`)
+
+			if key == "q" {
+				q, err := strconv.ParseFloat(value, 64)
+				if err != nil || q < 0 || q > 1 {
+					q = 0
+				}
+				qv.q = q
+				continue
+			}
+
+			if qv.params == nil {
+				qv.params = make(map[string]string)
+			}
+			qv.params[key] = value
+		}
+
+		values = append(values, qv)
+	}
+
+	return values
+}
+
+// encodingQuality returns the quality the Accept-Encoding header gives to a content coding.
+func encodingQuality(header, coding string) float64 {
+	wildcard := -1.0
+	for _, qv := range parseQualityList(header) {
+		if qv.value == coding {
+			return qv.q
+		}
+		if qv.value == "*" {
+			wildcard = qv.q
+		}
+	}
+
+	if wildcard >= 0 {
+		return wildcard
+	}
+
+	// identity is acceptable unless excluded
+	if coding == "identity" {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,45 @@
+package powermux
+
+import (
+	"testing"
+)
+
+func TestParseQualityList(t *testing.T) {
+	values := parseQualityList(`text/HTML;level=1, application/json;q=0.5, , */*;q=bad`)
+
+	if len(values) != 3 {
+		t.Fatal("Wrong number of values", len(values))
+	}
+
+	if values[0].value != "text/html" || values[0].q != 1 || values[0].params["level"] != "1" {
+		t.Error("Wrong first value", values[0])
+	}
+
+	if values[1].value != "application/json" || values[1].q != 0.5 {
+		t.Error("Wrong second value", values[1])
+	}
+
+	if values[2].q != 0 {
+		t.Error("Invalid quality not zeroed", values[2])
+	}
+}
+
+func TestEncodingQuality(t *testing.T) {
+	tests := []struct {
+		header string
+		coding string
+		q      float64
+	}{
+		{"gzip, br;q=0.5", "br", 0.5},
+		{"gzip", "br", 0},
+		{"*;q=0.2", "br", 0.2},
+		{"", "identity", 1},
+		{"identity;q=0", "identity", 0},
+	}
+
+	for _, test := range tests {
+		if q := encodingQuality(test.header, test.coding); q != test.q {
+			t.Errorf("Wrong quality for %s in %q: %v", test.coding, test.header, q)
+		}
+	}
+}
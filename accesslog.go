@@ -0,0 +1,202 @@
+package powermux
+
+import (
+	"log/slog"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// AccessLogFormat selects how the AccessLog middleware describes each request.
+type AccessLogFormat int
+
+const (
+	// AccessLogJSON logs each request as structured attributes. Paired with a slog.JSONHandler
+	// this produces one JSON object per line.
+	AccessLogJSON AccessLogFormat = iota
+	// AccessLogCommon logs each request as a message in the Common Log Format.
+	AccessLogCommon
+	// AccessLogCombined logs each request as a message in the Combined Log Format, which adds the
+	// referer and user agent to the Common Log Format.
+	AccessLogCombined
+)
+
+// clfTimeFormat is the timestamp layout of the Common Log Format
+const clfTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// AccessLog is a middleware that logs every request once its response is complete.
+//
+// Each entry includes the status, response size, duration, route pattern, host and path parameters of the request.
+// Server errors are logged at error level, everything else at info level.
+type AccessLog struct {
+	// Logger receives the entries. If nil, slog.Default() is used.
+	Logger *slog.Logger
+
+	// Format selects the entry format, AccessLogJSON by default.
+	Format AccessLogFormat
+
+	// Suppress lists route patterns, as returned by RequestPath, that are not logged.
+	// This is intended for noisy endpoints such as health checks.
+	Suppress []string
+}
+
+// accessLogEntry holds everything known about a completed request
+type accessLogEntry struct {
+	req      *http.Request
+	start    time.Time
+	duration time.Duration
+	status   int
+	size     int64
+	pattern  string
+}
+
+// ServeHTTPMiddleware logs the request after the rest of the chain has run.
+func (m *AccessLog) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	pattern := RequestPath(req)
+	for _, suppressed := range m.Suppress {
+		if pattern == suppressed {
+			next(rw, req)
+			return
+		}
+	}
+
+	w := newResponseWriter(rw)
+	start := time.Now()
+
+	// log even if the handler panics
+	completed := false
+	defer func() {
+		status := w.Status()
+		if !completed && !w.Written() {
+			status = http.StatusInternalServerError
+		}
+		m.log(&accessLogEntry{
+			req:      req,
+			start:    start,
+			duration: time.Since(start),
+			status:   status,
+			size:     w.Size(),
+			pattern:  pattern,
+		})
+	}()
+
+	next(w, req)
+	completed = true
+}
+
+// log writes the entry in the configured format
+func (m *AccessLog) log(e *accessLogEntry) {
+	logger := m.Logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+
+	level := slog.LevelInfo
+	if e.status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+
+	// the request context lets handlers pick up values such as trace IDs
+	ctx := e.req.Context()
+
+	switch m.Format {
+	case AccessLogCommon:
+		logger.LogAttrs(ctx, level, e.common())
+	case AccessLogCombined:
+		logger.LogAttrs(ctx, level, e.combined())
+	default:
+		logger.LogAttrs(ctx, level, "request", e.attrs()...)
+	}
+}
+
+// attrs returns the structured form of the entry
+func (e *accessLogEntry) attrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String("method", e.req.Method),
+		slog.String("host", e.req.Host),
+		slog.String("path", e.req.URL.Path),
+		slog.String("route", e.pattern),
+		slog.Int("status", e.status),
+		slog.Int64("size", e.size),
+		slog.Duration("duration", e.duration),
+		slog.String("remote_addr", e.req.RemoteAddr),
+		slog.String("proto", e.req.Proto),
+	}
+
+	if id := RequestID(e.req); id != "" {
+		attrs = append(attrs, slog.String("request_id", id))
+	}
+	if ua := e.req.UserAgent(); ua != "" {
+		attrs = append(attrs, slog.String("user_agent", ua))
+	}
+	if ref := e.req.Referer(); ref != "" {
+		attrs = append(attrs, slog.String("referer", ref))
+	}
+
+	params := PathParams(e.req)
+	if len(params) > 0 {
+		paramAttrs := make([]interface{}, 0, len(params))
+		for k, v := range params {
+			paramAttrs = append(paramAttrs, slog.String(k, v))
+		}
+		attrs = append(attrs, slog.Group("params", paramAttrs...))
+	}
+
+	return attrs
+}
+
+// common formats the entry in the Common Log Format
+func (e *accessLogEntry) common() string {
+	host, _, err := net.SplitHostPort(e.req.RemoteAddr)
+	if err != nil {
+		host = e.req.RemoteAddr
+	}
+
+	user := "-"
+	if e.req.URL.User != nil {
+		user = e.req.URL.User.Username()
+	} else if name, _, ok := e.req.BasicAuth(); ok && name != "" {
+		user = name
+	}
+
+	size := "-"
+	if e.size > 0 {
+		size = strconv.FormatInt(e.size, 10)
+	}
+
+	buf := strings.Builder{}
+	buf.WriteString(clfField(host))
+	buf.WriteString(" - ")
+	buf.WriteString(clfField(user))
+	buf.WriteString(" [")
+	buf.WriteString(e.start.Format(clfTimeFormat))
+	buf.WriteString("] \"")
+	buf.WriteString(clfEscape(e.req.Method + " " + e.req.URL.RequestURI() + " " + e.req.Proto))
+	buf.WriteString("\" ")
+	buf.WriteString(strconv.Itoa(e.status))
+	buf.WriteString(" ")
+	buf.WriteString(size)
+	return buf.String()
+}
+
+// combined formats the entry in the Combined Log Format
+func (e *accessLogEntry) combined() string {
+	return e.common() + " \"" + clfEscape(e.req.Referer()) + "\" \"" + clfEscape(e.req.UserAgent()) + "\""
+}
+
+// clfField escapes an unquoted field, using "-" for empty values
+func clfField(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(clfEscape(v), " ", "%20")
+}
+
+var clfEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, "\n", `\n`, "\r", `\r`)
+
+// clfEscape escapes quotes and line breaks so entries can't be forged
+func clfEscape(v string) string {
+	return clfEscaper.Replace(v)
+}
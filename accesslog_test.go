@@ -0,0 +1,159 @@
+package powermux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// messageHandler writes only the message of each record
+type messageHandler struct {
+	w io.Writer
+}
+
+func (h messageHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return true
+}
+
+func (h messageHandler) Handle(ctx context.Context, r slog.Record) error {
+	_, err := io.WriteString(h.w, r.Message+"\n")
+	return err
+}
+
+func (h messageHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h
+}
+
+func (h messageHandler) WithGroup(name string) slog.Handler {
+	return h
+}
+
+func TestAccessLog_JSON(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewServeMux()
+
+	s.Route("/").Middleware(&AccessLog{
+		Logger: slog.New(slog.NewJSONHandler(buf, nil)),
+	})
+	s.Route("/users/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, "hello")
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew", nil)
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal("Entry isn't JSON", err, buf.String())
+	}
+
+	if entry["route"] != "/users/:id" || entry["path"] != "/users/andrew" {
+		t.Error("Wrong route or path", entry)
+	}
+
+	if entry["status"] != float64(http.StatusCreated) || entry["size"] != float64(5) {
+		t.Error("Wrong status or size", entry)
+	}
+
+	if entry["host"] != "example.com" {
+		t.Error("Wrong host", entry["host"])
+	}
+
+	params, _ := entry["params"].(map[string]interface{})
+	if params["id"] != "andrew" {
+		t.Error("Params not logged", entry["params"])
+	}
+
+	if _, ok := entry["duration"]; !ok {
+		t.Error("Duration not logged")
+	}
+}
+
+func TestAccessLog_Common(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewServeMux()
+
+	s.Route("/").
+		Middleware(&AccessLog{
+			Logger: slog.New(messageHandler{buf}),
+			Format: AccessLogCommon,
+		}).
+		GetFunc(dummyHandlerFunc("hello"))
+
+	req := httptest.NewRequest(http.MethodGet, "/?q=1", nil)
+	req.SetBasicAuth("andrew", "secret")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	clf := regexp.MustCompile(`^192\.0\.2\.1 - andrew \[[^\]]+\] "GET /\?q=1 HTTP/1\.1" 200 5\n$`)
+	if !clf.MatchString(buf.String()) {
+		t.Error("Not in common log format", buf.String())
+	}
+}
+
+func TestAccessLog_Combined(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewServeMux()
+
+	s.Route("/").
+		Middleware(&AccessLog{
+			Logger: slog.New(messageHandler{buf}),
+			Format: AccessLogCombined,
+		}).
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("User-Agent", `evil"agent`)
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if !strings.HasSuffix(buf.String(), `" 200 - "" "evil\"agent"`+"\n") {
+		t.Error("Not in combined log format", buf.String())
+	}
+}
+
+func TestAccessLog_Suppress(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewServeMux()
+
+	s.Route("/").Middleware(&AccessLog{
+		Logger:   slog.New(slog.NewJSONHandler(buf, nil)),
+		Suppress: []string{"/healthz"},
+	})
+	s.Route("/healthz").GetFunc(dummyHandlerFunc("ok"))
+	s.Route("/users").GetFunc(dummyHandlerFunc("ok"))
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/healthz", nil))
+
+	if buf.Len() != 0 {
+		t.Error("Suppressed route was logged", buf.String())
+	}
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if buf.Len() == 0 {
+		t.Error("Route was not logged")
+	}
+}
+
+func TestAccessLog_ServerErrorLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	s := NewServeMux()
+
+	s.Route("/").
+		Middleware(&Recovery{Logger: PanicLoggerFunc(func(*http.Request, interface{}, []byte) {})}).
+		Middleware(&AccessLog{Logger: slog.New(slog.NewJSONHandler(buf, nil))}).
+		Get(panicHandler("boom"))
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if !strings.Contains(buf.String(), `"level":"ERROR"`) || !strings.Contains(buf.String(), `"status":500`) {
+		t.Error("Panic not logged as a server error", buf.String())
+	}
+}
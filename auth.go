@@ -0,0 +1,385 @@
+package powermux
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// ErrInvalidCredentials is returned by authenticators when the credentials of a request are not valid.
+var ErrInvalidCredentials = errors.New("powermux: invalid credentials")
+
+// Identity is who a request was authenticated as.
+type Identity struct {
+	// Subject identifies the user or client, such as a user ID
+	Subject string
+	// Scopes are the permissions granted to the identity
+	Scopes []string
+	// Claims holds any other details provided by the authenticator, such as token claims
+	Claims map[string]interface{}
+}
+
+// HasScope returns whether the identity was granted the scope.
+func (id *Identity) HasScope(scope string) bool {
+	for _, s := range id.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Authenticator identifies requests from their credentials.
+type Authenticator interface {
+	// Authenticate returns the identity of the request, or nil if it carries no credentials of the kind accepted.
+	// Invalid credentials are reported with an error, usually ErrInvalidCredentials.
+	Authenticate(req *http.Request) (*Identity, error)
+
+	// Challenge returns the WWW-Authenticate challenge sent to unauthenticated requests
+	Challenge() string
+}
+
+// BearerAuth authenticates requests with a token in an "Authorization: Bearer" header.
+type BearerAuth struct {
+	// Realm is sent in the challenge if not empty
+	Realm string
+
+	// Validate returns the identity a token belongs to, or an error if the token isn't valid
+	Validate func(req *http.Request, token string) (*Identity, error)
+}
+
+// Authenticate validates the bearer token of the request, if it has one
+func (a *BearerAuth) Authenticate(req *http.Request) (*Identity, error) {
+	scheme, token, ok := strings.Cut(req.Header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return nil, nil
+	}
+	return validIdentity(a.Validate(req, strings.TrimSpace(token)))
+}
+
+// Challenge returns the Bearer challenge
+func (a *BearerAuth) Challenge() string {
+	return challenge("Bearer", a.Realm)
+}
+
+// BasicAuth authenticates requests with HTTP Basic authentication.
+type BasicAuth struct {
+	// Realm is sent in the challenge if not empty
+	Realm string
+
+	// Validate returns the identity of a user, or an error if the password is wrong. StaticBasicAuth can be used
+	// for fixed sets of users.
+	Validate func(req *http.Request, username, password string) (*Identity, error)
+}
+
+// Authenticate validates the username and password of the request, if it has them
+func (a *BasicAuth) Authenticate(req *http.Request) (*Identity, error) {
+	username, password, ok := req.BasicAuth()
+	if !ok {
+		return nil, nil
+	}
+	return validIdentity(a.Validate(req, username, password))
+}
+
+// Challenge returns the Basic challenge
+func (a *BasicAuth) Challenge() string {
+	return challenge("Basic", a.Realm)
+}
+
+// StaticBasicAuth returns a BasicAuth validator for a fixed map of usernames to passwords.
+// The identity of each user has the username as its subject and no scopes.
+func StaticBasicAuth(users map[string]string) func(req *http.Request, username, password string) (*Identity, error) {
+	return func(req *http.Request, username, password string) (*Identity, error) {
+		expected, ok := users[username]
+		if !ok || subtle.ConstantTimeCompare([]byte(expected), []byte(password)) != 1 {
+			return nil, ErrInvalidCredentials
+		}
+		return &Identity{Subject: username}, nil
+	}
+}
+
+// APIKeyAuth authenticates requests with a key in a header or query parameter.
+type APIKeyAuth struct {
+	// Header holds the key, "X-API-Key" by default
+	Header string
+
+	// Query is a query parameter checked for the key if the header is missing. Keys in URLs tend to be logged,
+	// so this is disabled unless set.
+	Query string
+
+	// Validate returns the identity a key belongs to, or an error if the key isn't valid
+	Validate func(req *http.Request, key string) (*Identity, error)
+}
+
+// Authenticate validates the key of the request, if it has one
+func (a *APIKeyAuth) Authenticate(req *http.Request) (*Identity, error) {
+	key := req.Header.Get(a.header())
+	if key == "" && a.Query != "" {
+		key = req.URL.Query().Get(a.Query)
+	}
+	if key == "" {
+		return nil, nil
+	}
+	return validIdentity(a.Validate(req, key))
+}
+
+// Challenge returns an APIKey challenge naming the header
+func (a *APIKeyAuth) Challenge() string {
+	return `APIKey header="` + a.header() + `"`
+}
+
+// header returns the name of the header holding the key
+func (a *APIKeyAuth) header() string {
+	if a.Header == "" {
+		return "X-API-Key"
+	}
+	return a.Header
+}
+
+// challenge formats a WWW-Authenticate challenge for a scheme
+func challenge(scheme, realm string) string {
+	if realm == "" {
+		return scheme
+	}
+	return scheme + ` realm="` + strings.ReplaceAll(realm, `"`, `\"`) + `"`
+}
+
+// validIdentity treats validators returning neither an identity nor an error as rejecting the credentials
+func validIdentity(id *Identity, err error) (*Identity, error) {
+	if err == nil && id == nil {
+		err = ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+	return id, nil
+}
+
+// Policy decides whether a request may reach a route.
+type Policy interface {
+	// Authorize returns whether the identity, nil for unauthenticated requests, may make the request
+	Authorize(req *http.Request, id *Identity) bool
+
+	// String describes the policy for route listings
+	String() string
+}
+
+// scopePolicy requires an identity with all of its scopes
+type scopePolicy []string
+
+// Scopes returns a policy requiring an identity with all the given scopes.
+// With no scopes, any authenticated request is allowed.
+func Scopes(scopes ...string) Policy {
+	return scopePolicy(scopes)
+}
+
+// Authorize checks the identity has every scope
+func (p scopePolicy) Authorize(req *http.Request, id *Identity) bool {
+	if id == nil {
+		return false
+	}
+	for _, scope := range p {
+		if !id.HasScope(scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// String lists the scopes required
+func (p scopePolicy) String() string {
+	if len(p) == 0 {
+		return "authenticated"
+	}
+	return strings.Join(p, " ")
+}
+
+// anyonePolicy allows every request
+type anyonePolicy struct{}
+
+// Anyone is a policy allowing every request, used to open routes below a protected one.
+var Anyone Policy = anyonePolicy{}
+
+// Authorize allows everything
+func (anyonePolicy) Authorize(req *http.Request, id *Identity) bool {
+	return true
+}
+
+// String describes the policy
+func (anyonePolicy) String() string {
+	return "anyone"
+}
+
+// Require restricts requests for every method of this route to identities with all the given scopes, or any
+// authenticated identity if none are given. It is shorthand for RequirePolicy(Scopes(scopes...)).
+func (r *Route) Require(scopes ...string) *Route {
+	return r.RequirePolicy(Scopes(scopes...))
+}
+
+// RequireFor restricts requests for one method of this route, as Require does for all of them.
+func (r *Route) RequireFor(method string, scopes ...string) *Route {
+	return r.RequirePolicyFor(method, Scopes(scopes...))
+}
+
+// RequirePolicy protects every method of this route with a policy, except OPTIONS, whose CORS preflight requests
+// carry no credentials. It doesn't matter whether handlers were registered before or after, so
+// Get(list).Post(create).RequirePolicy(p) protects both methods with p. Use RequirePolicyFor to protect a single
+// method.
+//
+// The policy is inherited by the routes below this one, which can set their own policy, or use Anyone to lift it.
+// A policy set for a method of this route with RequirePolicyFor takes precedence over this one. Generated
+// responses, such as Not Found and Method Not Allowed, are sent without checking any policy.
+//
+// Policies are checked once the Authentication middleware has identified the request. Requests that fail a
+// policy are rejected with a 401 Unauthorized if they weren't authenticated, and a 403 Forbidden otherwise.
+// Requests are rejected with a 401 if no Authentication middleware ran.
+func (r *Route) RequirePolicy(p Policy) *Route {
+	r.policies[methodAny] = p
+	return r
+}
+
+// RequirePolicyFor protects one method of this route with a policy, as RequirePolicy does for all of them.
+// HEAD requests are protected by the GET policy unless they have their own.
+func (r *Route) RequirePolicyFor(method string, p Policy) *Route {
+	r.policies[method] = p
+	return r
+}
+
+// policyFor returns the policy for a method set on this route, if any
+func (r *Route) policyFor(method string) (Policy, bool) {
+	if p, ok := r.policies[method]; ok {
+		return p, true
+	}
+	if method == http.MethodHead {
+		if p, ok := r.policies[http.MethodGet]; ok {
+			return p, true
+		}
+	}
+	// CORS preflights carry no credentials
+	if method == http.MethodOptions {
+		return nil, false
+	}
+	p, ok := r.policies[methodAny]
+	return p, ok
+}
+
+// policyStrings describes the policies protecting each method of a route, given the routes above it
+func policyStrings(chain []*Route, methods []string) []string {
+	descriptions := make([]string, 0, len(methods))
+	for _, method := range methods {
+		if method == notFound {
+			continue
+		}
+
+		var policy Policy
+		for _, route := range chain {
+			if p, ok := route.policyFor(method); ok {
+				policy = p
+			}
+		}
+		if policy != nil {
+			descriptions = append(descriptions, method+": "+policy.String())
+		}
+	}
+	sort.Strings(descriptions)
+	return descriptions
+}
+
+type authCtxKeyType string
+
+var authCtxKey = authCtxKeyType("auth")
+
+// authState is what the Authentication middleware found out about a request
+type authState struct {
+	identity *Identity
+	auth     *Authentication
+}
+
+// RequestIdentity returns the identity the request was authenticated as, or nil if it wasn't.
+func RequestIdentity(req *http.Request) *Identity {
+	state, _ := req.Context().Value(authCtxKey).(*authState)
+	if state == nil {
+		return nil
+	}
+	return state.identity
+}
+
+// Authentication is a middleware that identifies requests with the first authenticator accepting their
+// credentials, making the identity available through RequestIdentity. Requests without credentials continue
+// unauthenticated, and it's left to route policies to reject them.
+//
+// Requests with invalid credentials are treated as unauthenticated.
+type Authentication struct {
+	// Authenticators are tried in order
+	Authenticators []Authenticator
+
+	// Unauthorized writes the response to requests rejected for not being authenticated, in place of a plain
+	// 401 Unauthorized. The WWW-Authenticate challenges are already set.
+	Unauthorized http.Handler
+
+	// Forbidden writes the response to authenticated requests rejected by a policy, in place of a plain
+	// 403 Forbidden. The identity is available through RequestIdentity.
+	Forbidden http.Handler
+}
+
+// ServeHTTPMiddleware authenticates the request and passes it on
+func (a *Authentication) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	state := &authState{
+		auth: a,
+	}
+
+	for _, authenticator := range a.Authenticators {
+		id, err := authenticator.Authenticate(req)
+		if err != nil {
+			break
+		}
+		if id != nil {
+			state.identity = id
+			break
+		}
+	}
+
+	next(rw, req.WithContext(context.WithValue(req.Context(), authCtxKey, state)))
+}
+
+// policyHandler serves a handler only to requests allowed by a policy
+type policyHandler struct {
+	handler http.Handler
+	policy  Policy
+}
+
+// ServeHTTP checks the policy before calling the handler
+func (h *policyHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	state, _ := req.Context().Value(authCtxKey).(*authState)
+	if state == nil {
+		state = &authState{
+			auth: &Authentication{},
+		}
+	}
+
+	if h.policy.Authorize(req, state.identity) {
+		h.handler.ServeHTTP(rw, req)
+		return
+	}
+
+	if state.identity == nil {
+		for _, authenticator := range state.auth.Authenticators {
+			rw.Header().Add("WWW-Authenticate", authenticator.Challenge())
+		}
+		if state.auth.Unauthorized != nil {
+			state.auth.Unauthorized.ServeHTTP(rw, req)
+			return
+		}
+		http.Error(rw, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+		return
+	}
+
+	if state.auth.Forbidden != nil {
+		state.auth.Forbidden.ServeHTTP(rw, req)
+		return
+	}
+	http.Error(rw, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+}
@@ -0,0 +1,226 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// tokens maps the bearer tokens accepted in tests to their identities
+var tokens = map[string]*Identity{
+	"reader": {Subject: "alice", Scopes: []string{"users:read"}},
+	"writer": {Subject: "bob", Scopes: []string{"users:read", "users:write"}},
+}
+
+func validateToken(req *http.Request, token string) (*Identity, error) {
+	if id, ok := tokens[token]; ok {
+		return id, nil
+	}
+	return nil, ErrInvalidCredentials
+}
+
+// identityHandler responds with the subject of the request's identity
+func identityHandler(w http.ResponseWriter, r *http.Request) {
+	if id := RequestIdentity(r); id != nil {
+		w.Write([]byte(id.Subject))
+	}
+}
+
+func authMux() *ServeMux {
+	s := NewServeMux()
+	s.Route("/").Middleware(&Authentication{
+		Authenticators: []Authenticator{
+			&BearerAuth{Realm: "api", Validate: validateToken},
+			&BasicAuth{Validate: StaticBasicAuth(map[string]string{"carol": "secret"})},
+		},
+	})
+	s.Route("/users").Require().
+		RequireFor(http.MethodGet, "users:read").
+		RequireFor(http.MethodPost, "users:write").
+		GetFunc(identityHandler).
+		PostFunc(identityHandler)
+	s.Route("/users/:id").GetFunc(identityHandler)
+	s.Route("/users/:id/avatar").RequirePolicy(Anyone).GetFunc(identityHandler)
+	s.Route("/profile").GetFunc(identityHandler).Require()
+	return s
+}
+
+func authRequest(s *ServeMux, method, path, authorization string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if authorization != "" {
+		req.Header.Set("Authorization", authorization)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthentication_Policies(t *testing.T) {
+	s := authMux()
+
+	tests := []struct {
+		method        string
+		path          string
+		authorization string
+		code          int
+	}{
+		{http.MethodGet, "/users", "", http.StatusUnauthorized},
+		{http.MethodGet, "/users", "Bearer nonsense", http.StatusUnauthorized},
+		{http.MethodGet, "/users", "Bearer reader", http.StatusOK},
+		{http.MethodHead, "/users", "Bearer reader", http.StatusOK},
+		{http.MethodPost, "/users", "Bearer reader", http.StatusForbidden},
+		{http.MethodPost, "/users", "Bearer writer", http.StatusOK},
+		{http.MethodGet, "/users/1", "", http.StatusUnauthorized},
+		{http.MethodGet, "/users/1", "Bearer reader", http.StatusOK},
+		{http.MethodGet, "/users/1", "Basic Y2Fyb2w6c2VjcmV0", http.StatusForbidden},
+		{http.MethodGet, "/users/1/avatar", "", http.StatusOK},
+		{http.MethodGet, "/profile", "Basic Y2Fyb2w6c2VjcmV0", http.StatusOK},
+		{http.MethodGet, "/profile", "Basic Y2Fyb2w6d3Jvbmc=", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		rec := authRequest(s, test.method, test.path, test.authorization)
+		if rec.Code != test.code {
+			t.Errorf("Wrong status for %s %s with %q: %d", test.method, test.path, test.authorization, rec.Code)
+		}
+	}
+}
+
+func TestAuthentication_Identity(t *testing.T) {
+	s := authMux()
+
+	if rec := authRequest(s, http.MethodGet, "/users/1", "Bearer writer"); rec.Body.String() != "bob" {
+		t.Error("Identity not available to handler", rec.Body.String())
+	}
+
+	if rec := authRequest(s, http.MethodGet, "/profile", "Basic Y2Fyb2w6c2VjcmV0"); rec.Body.String() != "carol" {
+		t.Error("Identity not available to handler", rec.Body.String())
+	}
+}
+
+func TestAuthentication_Challenges(t *testing.T) {
+	s := authMux()
+
+	rec := authRequest(s, http.MethodGet, "/users", "")
+	challenges := rec.Header().Values("WWW-Authenticate")
+	if len(challenges) != 2 || challenges[0] != `Bearer realm="api"` || challenges[1] != "Basic" {
+		t.Error("Wrong challenges", challenges)
+	}
+
+	rec = authRequest(s, http.MethodPost, "/users", "Bearer reader")
+	if rec.Header().Get("WWW-Authenticate") != "" {
+		t.Error("Challenge sent to authenticated request")
+	}
+}
+
+func TestAuthentication_Handlers(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(&Authentication{
+		Authenticators: []Authenticator{&APIKeyAuth{
+			Query: "key",
+			Validate: func(req *http.Request, key string) (*Identity, error) {
+				if key == "k1" {
+					return &Identity{Subject: "service"}, nil
+				}
+				return nil, nil
+			},
+		}},
+		Unauthorized: dummyHandler("who are you"),
+		Forbidden:    dummyHandler("not you"),
+	})
+	s.Route("/admin").Require("admin").Get(dummyHandler("admin"))
+
+	rec := authRequest(s, http.MethodGet, "/admin", "")
+	if rec.Body.String() != "who are you" || rec.Header().Get("WWW-Authenticate") != `APIKey header="X-API-Key"` {
+		t.Error("Unauthorized handler not used", rec.Body.String(), rec.Header())
+	}
+
+	rec = authRequest(s, http.MethodGet, "/admin?key=k2", "")
+	if rec.Body.String() != "who are you" {
+		t.Error("Validator without identity accepted", rec.Body.String())
+	}
+
+	rec = authRequest(s, http.MethodGet, "/admin?key=k1", "")
+	if rec.Body.String() != "not you" {
+		t.Error("Forbidden handler not used", rec.Body.String())
+	}
+}
+
+func TestRequirePolicy_WithoutAuthentication(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/secret").Require().Get(dummyHandler("secret"))
+
+	if rec := authRequest(s, http.MethodGet, "/secret", ""); rec.Code != http.StatusUnauthorized {
+		t.Error("Protected route served without authentication", rec.Code)
+	}
+}
+
+func TestRequire_AllMethods(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(&Authentication{
+		Authenticators: []Authenticator{&BearerAuth{Validate: validateToken}},
+	})
+	s.Route("/users").
+		GetFunc(identityHandler).
+		PostFunc(identityHandler).
+		Require("users:write")
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		if rec := authRequest(s, method, "/users", ""); rec.Code != http.StatusUnauthorized {
+			t.Error("Method not protected", method, rec.Code)
+		}
+		if rec := authRequest(s, method, "/users", "Bearer reader"); rec.Code != http.StatusForbidden {
+			t.Error("Method not protected", method, rec.Code)
+		}
+		if rec := authRequest(s, method, "/users", "Bearer writer"); rec.Code != http.StatusOK {
+			t.Error("Method not allowed", method, rec.Code)
+		}
+	}
+}
+
+func TestRequire_GeneratedResponses(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(&Authentication{
+		Authenticators: []Authenticator{&BearerAuth{Validate: validateToken}},
+	})
+	s.Route("/users").GetFunc(identityHandler).Require("users:read")
+	s.Route("/items").GetFunc(identityHandler).Options(dummyHandler("preflight")).Require("users:read")
+
+	tests := []struct {
+		method string
+		path   string
+		code   int
+	}{
+		{http.MethodGet, "/users", http.StatusUnauthorized},
+		{http.MethodDelete, "/users", http.StatusMethodNotAllowed},
+		{http.MethodGet, "/users/nope", http.StatusNotFound},
+		{http.MethodOptions, "/users", http.StatusMethodNotAllowed},
+		{http.MethodOptions, "/items", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		if rec := authRequest(s, test.method, test.path, ""); rec.Code != test.code {
+			t.Errorf("Wrong status for %s %s: %d", test.method, test.path, rec.Code)
+		}
+	}
+}
+
+func TestRequirePolicy_String(t *testing.T) {
+	s := authMux()
+	routes := s.String()
+
+	expected := []string{
+		"/users\t[",
+		"\tGET: users:read, POST: users:write\n",
+		"/users/:id\t[GET]\tGET: users:read\n",
+		"/users/:id/avatar\t[GET]\tGET: anyone\n",
+		"/profile\t[GET]\tGET: authenticated\n",
+	}
+
+	for _, e := range expected {
+		if !strings.Contains(routes, e) {
+			t.Errorf("Listing doesn't contain %q:\n%s", e, routes)
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package powermux
+
+import (
+	"net/http"
+)
+
+// BodyLimitOptions configures a request body limit. The zero value is a usable configuration.
+type BodyLimitOptions struct {
+	// Handler writes the response to requests declaring a body larger than the limit,
+	// in place of a plain 413 Request Entity Too Large.
+	Handler http.Handler
+}
+
+// routeBodyLimit is the request body limit that applies to a route and those below it
+type routeBodyLimit struct {
+	limit   int64
+	handler http.Handler
+}
+
+// MaxBodyBytes limits the size of request bodies sent to this route and every route below it.
+//
+// Requests whose Content-Length exceeds n are rejected with a 413 Request Entity Too Large, or the response
+// written by opts.Handler, before any middleware runs. Other request bodies are wrapped with http.MaxBytesReader,
+// so reading past the limit fails with an *http.MaxBytesError.
+//
+// Routes below this one can set their own limit, or remove it by passing zero.
+func (r *Route) MaxBodyBytes(n int64, opts *BodyLimitOptions) *Route {
+	l := &routeBodyLimit{
+		limit: n,
+	}
+	if opts != nil {
+		l.handler = opts.Handler
+	}
+
+	r.bodyLimit = l
+	return r
+}
+
+// apply limits the request body, returning false if the request was rejected
+func (l *routeBodyLimit) apply(rw http.ResponseWriter, req *http.Request) bool {
+	if l.limit <= 0 {
+		return true
+	}
+
+	if req.ContentLength > l.limit {
+		if l.handler != nil {
+			l.handler.ServeHTTP(rw, req)
+		} else {
+			http.Error(rw, http.StatusText(http.StatusRequestEntityTooLarge), http.StatusRequestEntityTooLarge)
+		}
+		return false
+	}
+
+	if req.Body != nil && req.Body != http.NoBody {
+		req.Body = http.MaxBytesReader(rw, req.Body, l.limit)
+	}
+	return true
+}
@@ -0,0 +1,100 @@
+package powermux
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// readBodyHandler reads the whole body, reporting whether it was too large
+func readBodyHandler(w http.ResponseWriter, r *http.Request) {
+	data, err := io.ReadAll(r.Body)
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		w.WriteHeader(http.StatusRequestEntityTooLarge)
+		return
+	}
+	w.Write(data)
+}
+
+func TestRoute_MaxBodyBytes(t *testing.T) {
+	s := NewServeMux()
+
+	middlewareRan := false
+	s.Route("/").
+		MaxBodyBytes(10, nil).
+		MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+			middlewareRan = true
+			n(w, r)
+		}).
+		PostFunc(readBodyHandler)
+
+	// rejected early
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("far too long a body")))
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Error("Oversized request not rejected", rec.Code)
+	}
+	if middlewareRan {
+		t.Error("Middleware ran for an oversized request")
+	}
+
+	// rejected while reading
+	req := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("far too long a body"))
+	req.ContentLength = -1
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Code != http.StatusRequestEntityTooLarge {
+		t.Error("Body without a length not limited", rec.Code)
+	}
+
+	// within the limit
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("short")))
+	if rec.Code != http.StatusOK || rec.Body.String() != "short" {
+		t.Error("Small request not passed on", rec.Code, rec.Body.String())
+	}
+}
+
+func TestRoute_MaxBodyBytesInheritance(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").MaxBodyBytes(10, nil)
+	s.Route("/api/items").PostFunc(readBodyHandler)
+	s.Route("/uploads").MaxBodyBytes(100, nil).PostFunc(readBodyHandler)
+	s.Route("/unlimited").MaxBodyBytes(0, nil).PostFunc(readBodyHandler)
+
+	body := strings.Repeat("x", 50)
+	tests := map[string]int{
+		"/api/items": http.StatusRequestEntityTooLarge,
+		"/uploads":   http.StatusOK,
+		"/unlimited": http.StatusOK,
+	}
+
+	for path, code := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+		if rec.Code != code {
+			t.Errorf("Wrong status for %s: %d", path, rec.Code)
+		}
+	}
+}
+
+func TestRoute_MaxBodyBytesHandler(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").MaxBodyBytes(10, &BodyLimitOptions{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/problem+json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			w.Write([]byte(`{"title":"too large"}`))
+		}),
+	}).PostFunc(readBodyHandler)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", strings.NewReader("far too long a body")))
+	if rec.Code != http.StatusRequestEntityTooLarge || rec.Body.String() != `{"title":"too large"}` {
+		t.Error("Custom handler not used", rec.Code, rec.Body.String())
+	}
+}
@@ -0,0 +1,183 @@
+package powermux
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CachePolicy describes how responses may be cached by clients and shared caches.
+// The zero value allows caching but requires revalidation, which the automatic ETags make cheap.
+type CachePolicy struct {
+	// MaxAge is how long responses stay fresh. It also sets the Expires header.
+	MaxAge time.Duration
+	// SMaxAge overrides MaxAge for shared caches if not zero.
+	SMaxAge time.Duration
+	// StaleWhileRevalidate is how long a stale response may be used while it is revalidated in the background.
+	StaleWhileRevalidate time.Duration
+
+	// Public allows shared caches to store responses to authenticated requests.
+	Public bool
+	// Private prevents shared caches from storing responses.
+	Private bool
+	// NoCache requires caches to revalidate responses before every use.
+	NoCache bool
+	// NoStore prevents responses being stored at all.
+	NoStore bool
+	// MustRevalidate prevents stale responses being used.
+	MustRevalidate bool
+	// Immutable promises responses won't change while they are fresh.
+	Immutable bool
+
+	// Vary lists the request headers responses depend on.
+	Vary []string
+}
+
+// cacheControl returns the Cache-Control header for the policy
+func (p *CachePolicy) cacheControl() string {
+	directives := make([]string, 0, 8)
+
+	if p.Public {
+		directives = append(directives, "public")
+	}
+	if p.Private {
+		directives = append(directives, "private")
+	}
+	if p.NoCache {
+		directives = append(directives, "no-cache")
+	}
+	if p.NoStore {
+		directives = append(directives, "no-store")
+	}
+	if !p.NoStore {
+		directives = append(directives, "max-age="+strconv.FormatInt(int64(p.MaxAge/time.Second), 10))
+	}
+	if p.SMaxAge > 0 {
+		directives = append(directives, "s-maxage="+strconv.FormatInt(int64(p.SMaxAge/time.Second), 10))
+	}
+	if p.StaleWhileRevalidate > 0 {
+		directives = append(directives, "stale-while-revalidate="+strconv.FormatInt(int64(p.StaleWhileRevalidate/time.Second), 10))
+	}
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+	if p.Immutable {
+		directives = append(directives, "immutable")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+// Cache sets the caching policy for GET and HEAD responses of this route.
+//
+// The policy is inherited by the routes below this one, which can set their own policy, or pass nil to remove it.
+// A policy set for a method of this route with CacheFor takes precedence over this one.
+//
+// Successful responses get Cache-Control, Vary and Expires headers unless the handler set them, and a weak ETag
+// computed from the body unless the handler set one. Requests with a matching If-None-Match, or failing that an
+// If-Modified-Since no earlier than the handler's Last-Modified, are answered with a 304 Not Modified.
+// Responses are buffered to compute the ETag, so the policy is unsuitable for streaming routes.
+func (r *Route) Cache(policy *CachePolicy) *Route {
+	r.cachePolicies[methodAny] = policy
+	return r
+}
+
+// CacheFor sets the caching policy for one method of this route, as Cache does for all of them.
+// HEAD responses follow the GET policy unless they have their own.
+func (r *Route) CacheFor(method string, policy *CachePolicy) *Route {
+	r.cachePolicies[method] = policy
+	return r
+}
+
+// cachePolicyFor returns the caching policy for a method set on this route, if any
+func (r *Route) cachePolicyFor(method string) (*CachePolicy, bool) {
+	if p, ok := r.cachePolicies[method]; ok {
+		return p, true
+	}
+	if method == http.MethodHead {
+		if p, ok := r.cachePolicies[http.MethodGet]; ok {
+			return p, true
+		}
+	}
+	p, ok := r.cachePolicies[methodAny]
+	return p, ok
+}
+
+// cacheHandler applies a caching policy to a handler's responses
+type cacheHandler struct {
+	handler http.Handler
+	policy  *CachePolicy
+}
+
+// ServeHTTP buffers the response, adds the caching headers and answers conditional requests
+func (h *cacheHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	buf := newResponseBuffer()
+	h.handler.ServeHTTP(buf, req)
+
+	status := buf.Status()
+	if status < http.StatusOK || status >= http.StatusMultipleChoices {
+		buf.writeTo(rw)
+		return
+	}
+
+	header := buf.Header()
+	if header.Get("Cache-Control") == "" {
+		header.Set("Cache-Control", h.policy.cacheControl())
+	}
+	for _, v := range h.policy.Vary {
+		header.Add("Vary", v)
+	}
+	if h.policy.MaxAge > 0 && !h.policy.NoStore && header.Get("Expires") == "" {
+		header.Set("Expires", time.Now().Add(h.policy.MaxAge).UTC().Format(http.TimeFormat))
+	}
+
+	if status == http.StatusOK && header.Get("ETag") == "" {
+		sum := sha256.Sum256(buf.body.Bytes())
+		header.Set("ETag", `W/"`+hex.EncodeToString(sum[:16])+`"`)
+	}
+
+	if status == http.StatusOK && notModified(req, header) {
+		// content headers describe a body that isn't sent
+		header.Del("Content-Type")
+		header.Del("Content-Length")
+		header.Del("Content-Encoding")
+		buf.status = http.StatusNotModified
+		buf.body.Reset()
+	}
+
+	buf.writeTo(rw)
+}
+
+// notModified returns whether the client's copy of the response is current
+func notModified(req *http.Request, header http.Header) bool {
+	if inm := req.Header.Get("If-None-Match"); inm != "" {
+		return etagMatches(inm, header.Get("ETag"))
+	}
+
+	ims, err := http.ParseTime(req.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+	modified, err := http.ParseTime(header.Get("Last-Modified"))
+	if err != nil {
+		return false
+	}
+	return !modified.After(ims)
+}
+
+// etagMatches compares an If-None-Match header against an ETag using the weak comparison
+func etagMatches(ifNoneMatch, etag string) bool {
+	if etag == "" {
+		return false
+	}
+	for _, candidate := range strings.Split(ifNoneMatch, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,155 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func cacheRequest(s *ServeMux, method, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoute_Cache(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/items").Cache(&CachePolicy{
+		MaxAge:               time.Minute,
+		StaleWhileRevalidate: 10 * time.Second,
+		Public:               true,
+		Vary:                 []string{"Accept-Language"},
+	}).Get(dummyHandler("items")).Post(dummyHandler("created"))
+
+	rec := cacheRequest(s, http.MethodGet, "/items", nil)
+	h := rec.Header()
+
+	if h.Get("Cache-Control") != "public, max-age=60, stale-while-revalidate=10" {
+		t.Error("Wrong Cache-Control", h.Get("Cache-Control"))
+	}
+	if h.Get("Vary") != "Accept-Language" {
+		t.Error("Wrong Vary", h.Get("Vary"))
+	}
+	if expires, err := http.ParseTime(h.Get("Expires")); err != nil || time.Until(expires) < 50*time.Second {
+		t.Error("Wrong Expires", h.Get("Expires"))
+	}
+	if !strings.HasPrefix(h.Get("ETag"), `W/"`) {
+		t.Error("Weak ETag not set", h.Get("ETag"))
+	}
+	if rec.Body.String() != "items" {
+		t.Error("Wrong body", rec.Body.String())
+	}
+
+	// unsafe methods are left alone
+	rec = cacheRequest(s, http.MethodPost, "/items", nil)
+	if rec.Header().Get("Cache-Control") != "" || rec.Header().Get("ETag") != "" {
+		t.Error("POST response given caching headers", rec.Header())
+	}
+}
+
+func TestRoute_CacheConditional(t *testing.T) {
+	modified := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	s := NewServeMux()
+	s.Route("/").Cache(&CachePolicy{})
+	s.Route("/report").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Last-Modified", modified.Format(http.TimeFormat))
+		w.Write([]byte("report"))
+	})
+
+	etag := cacheRequest(s, http.MethodGet, "/report", nil).Header().Get("ETag")
+
+	tests := []struct {
+		header http.Header
+		code   int
+	}{
+		{http.Header{"If-None-Match": {etag}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"other", ` + strings.TrimPrefix(etag, "W/")}}, http.StatusNotModified},
+		{http.Header{"If-None-Match": {`"other"`}}, http.StatusOK},
+		{http.Header{"If-Modified-Since": {modified.Format(http.TimeFormat)}}, http.StatusNotModified},
+		{http.Header{"If-Modified-Since": {modified.Add(-time.Hour).Format(http.TimeFormat)}}, http.StatusOK},
+		{http.Header{"If-None-Match": {`"other"`}, "If-Modified-Since": {modified.Format(http.TimeFormat)}}, http.StatusOK},
+	}
+
+	for _, test := range tests {
+		rec := cacheRequest(s, http.MethodGet, "/report", test.header)
+		if rec.Code != test.code {
+			t.Errorf("Wrong status for %v: %d", test.header, rec.Code)
+		}
+		if rec.Code == http.StatusNotModified {
+			if rec.Body.Len() != 0 || rec.Header().Get("Content-Type") != "" {
+				t.Error("Content sent with 304", rec.Body.String(), rec.Header())
+			}
+			if rec.Header().Get("ETag") != etag || rec.Header().Get("Cache-Control") != "max-age=0" {
+				t.Error("Caching headers missing from 304", rec.Header())
+			}
+		}
+	}
+}
+
+func TestRoute_CacheInheritance(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/api").Cache(&CachePolicy{MaxAge: time.Minute})
+	s.Route("/api/items").Get(dummyHandler("items"))
+	s.Route("/api/users").Get(dummyHandler("users")).Cache(&CachePolicy{Private: true, NoCache: true})
+	s.Route("/api/live").Cache(nil).Get(dummyHandler("live"))
+	s.Route("/api/fail").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	s.Route("/api/own").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-store")
+		w.Header().Set("ETag", `"v1"`)
+	})
+
+	tests := map[string]string{
+		"/api/items": "max-age=60",
+		"/api/users": "private, no-cache, max-age=0",
+		"/api/live":  "",
+		"/api/fail":  "",
+		"/api/own":   "no-store",
+	}
+
+	for path, cacheControl := range tests {
+		rec := cacheRequest(s, http.MethodGet, path, nil)
+		if rec.Header().Get("Cache-Control") != cacheControl {
+			t.Errorf("Wrong Cache-Control for %s: %q", path, rec.Header().Get("Cache-Control"))
+		}
+	}
+
+	if etag := cacheRequest(s, http.MethodGet, "/api/own", nil).Header().Get("ETag"); etag != `"v1"` {
+		t.Error("Handler ETag replaced", etag)
+	}
+
+	// HEAD follows the GET policy
+	if rec := cacheRequest(s, http.MethodHead, "/api/users", nil); rec.Header().Get("Cache-Control") != "private, no-cache, max-age=0" {
+		t.Error("HEAD request didn't use GET policy", rec.Header().Get("Cache-Control"))
+	}
+}
+
+func TestRoute_CacheAllMethods(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/items").
+		Get(dummyHandler("items")).
+		Post(dummyHandler("created")).
+		Cache(&CachePolicy{MaxAge: time.Minute})
+	s.Route("/items/:id").
+		Get(dummyHandler("item")).
+		CacheFor(http.MethodGet, &CachePolicy{Private: true})
+
+	if rec := cacheRequest(s, http.MethodGet, "/items", nil); rec.Header().Get("Cache-Control") != "max-age=60" {
+		t.Error("Route policy not applied to GET", rec.Header().Get("Cache-Control"))
+	}
+	if rec := cacheRequest(s, http.MethodHead, "/items", nil); rec.Header().Get("Cache-Control") != "max-age=60" {
+		t.Error("Route policy not applied to HEAD", rec.Header().Get("Cache-Control"))
+	}
+	if rec := cacheRequest(s, http.MethodHead, "/items/1", nil); rec.Header().Get("Cache-Control") != "private, max-age=0" {
+		t.Error("HEAD request didn't use GET policy", rec.Header().Get("Cache-Control"))
+	}
+}
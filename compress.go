@@ -0,0 +1,335 @@
+package powermux
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// DefaultCompressibleTypes are the media types compressed when no others are given.
+// Entries ending in "/*" match every subtype.
+var DefaultCompressibleTypes = []string{
+	"text/*",
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"application/xhtml+xml",
+	"application/wasm",
+	"image/svg+xml",
+}
+
+// DefaultCompressionMinSize is the smallest response body compressed when no other size is given
+const DefaultCompressionMinSize = 1024
+
+// Compression is a middleware that compresses response bodies with gzip or deflate, whichever the client prefers
+// according to its Accept-Encoding header.
+//
+// Only responses with one of the listed content types and a body of at least MinSize bytes are compressed.
+// Responses that already have a Content-Encoding, partial content and responses to routes that turned compression
+// off with Route.Compression are sent unchanged. Every response that could have been compressed gets a
+// "Vary: Accept-Encoding" header, so caches keep the compressed and uncompressed versions apart.
+type Compression struct {
+	level int
+	gzip  sync.Pool
+	zlib  sync.Pool
+
+	// MinSize is the smallest body compressed. Smaller bodies are buffered until the response ends or is flushed.
+	MinSize int
+
+	// ContentTypes lists the media types compressed. Entries ending in "/*" match every subtype.
+	ContentTypes []string
+}
+
+// NewCompression creates a Compression middleware using the given level, as defined by compress/flate.
+// It panics if the level is invalid.
+func NewCompression(level int) *Compression {
+	if level < flate.HuffmanOnly || level > flate.BestCompression {
+		panic("powermux: invalid compression level " + strconv.Itoa(level))
+	}
+
+	return &Compression{
+		level:        level,
+		MinSize:      DefaultCompressionMinSize,
+		ContentTypes: DefaultCompressibleTypes,
+	}
+}
+
+// Compression turns response compression on or off for this route and every route below it.
+// Routes that stream their responses or serve content that is already compressed should turn it off.
+func (r *Route) Compression(enabled bool) *Route {
+	r.compression = &enabled
+	return r
+}
+
+// ServeHTTPMiddleware compresses the response of the rest of the chain if the client accepts it
+func (c *Compression) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	if ex := getRequestExecution(req); ex != nil && ex.compression != nil && !*ex.compression {
+		next(rw, req)
+		return
+	}
+
+	w := &compressWriter{
+		ResponseWriter: rw,
+		c:              c,
+		encoding:       negotiateEncoding(req.Header.Get("Accept-Encoding")),
+	}
+	next(w, req)
+	w.close()
+}
+
+// negotiateEncoding returns the content coding the client prefers, gzip if it has no preference,
+// or an empty string if it accepts neither
+func negotiateEncoding(accept string) string {
+	gz := encodingQuality(accept, "gzip")
+	deflate := encodingQuality(accept, "deflate")
+
+	switch {
+	case gz > 0 && gz >= deflate:
+		return "gzip"
+	case deflate > 0:
+		return "deflate"
+	}
+	return ""
+}
+
+// compressible returns whether the media type is on the allow list
+func (c *Compression) compressible(contentType string) bool {
+	mediaType, _, _ := strings.Cut(contentType, ";")
+	mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+	if mediaType == "" {
+		return false
+	}
+
+	for _, t := range c.ContentTypes {
+		if prefix, ok := strings.CutSuffix(t, "*"); ok {
+			if strings.HasPrefix(mediaType, prefix) {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// getWriter returns a pooled compressor for the encoding writing to dst
+func (c *Compression) getWriter(encoding string, dst io.Writer) io.WriteCloser {
+	if encoding == "gzip" {
+		if gw, ok := c.gzip.Get().(*gzip.Writer); ok {
+			gw.Reset(dst)
+			return gw
+		}
+		gw, _ := gzip.NewWriterLevel(dst, c.level)
+		return gw
+	}
+
+	if zw, ok := c.zlib.Get().(*zlib.Writer); ok {
+		zw.Reset(dst)
+		return zw
+	}
+	zw, _ := zlib.NewWriterLevel(dst, c.level)
+	return zw
+}
+
+// putWriter returns a compressor to its pool
+func (c *Compression) putWriter(w io.WriteCloser) {
+	switch w := w.(type) {
+	case *gzip.Writer:
+		c.gzip.Put(w)
+	case *zlib.Writer:
+		c.zlib.Put(w)
+	}
+}
+
+// compressWriter buffers the start of a response until it can decide whether to compress it.
+//
+// Like responseWriter, it always implements http.Flusher, http.Hijacker and io.ReaderFrom.
+type compressWriter struct {
+	http.ResponseWriter
+	c        *Compression
+	encoding string
+	status   int
+	buf      []byte
+	decided  bool
+	hijacked bool
+	// the compressor, if the response is being compressed
+	cw io.WriteCloser
+}
+
+// WriteHeader holds the status code until the response is started, passing informational responses straight on
+func (w *compressWriter) WriteHeader(code int) {
+	if code < http.StatusOK {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+	if w.status == 0 {
+		w.status = code
+	}
+}
+
+// Write buffers the body until there's enough to decide whether to compress it
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.c.MinSize {
+			return len(p), nil
+		}
+		if err := w.start(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+
+	if w.cw != nil {
+		return w.cw.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// start decides whether to compress the response, sends the header and any buffered body.
+// large is whether the body is known to be at least MinSize.
+func (w *compressWriter) start(large bool) error {
+	w.decided = true
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+
+	h := w.Header()
+	if h.Get("Content-Type") == "" && len(w.buf) > 0 && h.Get("Content-Encoding") == "" {
+		// as the http server would, so the type can be checked
+		h.Set("Content-Type", http.DetectContentType(w.buf))
+	}
+
+	if w.eligible() {
+		h.Add("Vary", "Accept-Encoding")
+
+		if cl, err := strconv.Atoi(h.Get("Content-Length")); err == nil && cl >= w.c.MinSize {
+			large = true
+		}
+
+		if w.encoding != "" && large {
+			h.Set("Content-Encoding", w.encoding)
+			h.Del("Content-Length")
+			// the compressed bytes differ, so a strong validator no longer holds
+			if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.cw = w.c.getWriter(w.encoding, w.ResponseWriter)
+		}
+	}
+
+	w.ResponseWriter.WriteHeader(w.status)
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	if w.cw != nil {
+		_, err := w.cw.Write(buf)
+		return err
+	}
+	_, err := w.ResponseWriter.Write(buf)
+	return err
+}
+
+// eligible returns whether the response could be compressed for a client accepting it
+func (w *compressWriter) eligible() bool {
+	switch w.status {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+
+	h := w.Header()
+	if h.Get("Content-Encoding") != "" || h.Get("Content-Range") != "" {
+		return false
+	}
+	return w.c.compressible(h.Get("Content-Type"))
+}
+
+// close finishes the response
+func (w *compressWriter) close() {
+	if w.hijacked {
+		return
+	}
+	if !w.decided {
+		w.start(false)
+	}
+	if w.cw != nil {
+		w.cw.Close()
+		w.c.putWriter(w.cw)
+		w.cw = nil
+	}
+}
+
+// Flush starts the response, compressing it regardless of size, and sends everything written so far
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		w.start(true)
+	}
+	if fw, ok := w.cw.(interface{ Flush() error }); ok {
+		fw.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection if the wrapped writer supports it
+func (w *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		conn, rw, err := h.Hijack()
+		if err == nil {
+			w.hijacked = true
+		}
+		return conn, rw, err
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// ReadFrom copies from src, using the wrapped writer's ReadFrom if the response isn't compressed
+func (w *compressWriter) ReadFrom(src io.Reader) (int64, error) {
+	var n int64
+
+	// hide our own ReadFrom from io.Copy to avoid recursing
+	if !w.decided {
+		remaining := int64(w.c.MinSize - len(w.buf))
+		if remaining < 1 {
+			remaining = 1
+		}
+		copied, err := io.CopyN(struct{ io.Writer }{w}, src, remaining)
+		n += copied
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+	}
+
+	if w.cw == nil {
+		if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+			copied, err := rf.ReadFrom(src)
+			return n + copied, err
+		}
+	}
+	copied, err := io.Copy(struct{ io.Writer }{w}, src)
+	return n + copied, err
+}
+
+// Unwrap returns the wrapped writer for use by http.ResponseController
+func (w *compressWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
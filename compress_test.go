@@ -0,0 +1,223 @@
+package powermux
+
+import (
+	"bytes"
+	"compress/flate"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var compressBody = strings.Repeat("compress me please ", 100)
+
+func compressRequest(s *ServeMux, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func textHandler(contentType, body string) http.HandlerFunc {
+	return func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", contentType)
+		io.WriteString(rw, body)
+	}
+}
+
+func decompress(t *testing.T, encoding string, body []byte) string {
+	var r io.Reader
+	var err error
+	switch encoding {
+	case "gzip":
+		r, err = gzip.NewReader(bytes.NewReader(body))
+	case "deflate":
+		r, err = zlib.NewReader(bytes.NewReader(body))
+	default:
+		return string(body)
+	}
+	if err != nil {
+		t.Fatal("Invalid compressed body", err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal("Invalid compressed body", err)
+	}
+	return string(data)
+}
+
+func TestCompression(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(NewCompression(flate.DefaultCompression))
+	s.Route("/text").Get(textHandler("text/plain; charset=utf-8", compressBody))
+	s.Route("/small").Get(textHandler("application/json", "{}"))
+	s.Route("/image").Get(textHandler("image/png", compressBody))
+	s.Route("/sniffed").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		io.WriteString(rw, "<html>"+compressBody)
+	})
+
+	tests := []struct {
+		path     string
+		accept   string
+		encoding string
+		vary     bool
+	}{
+		{"/text", "gzip, deflate", "gzip", true},
+		{"/text", "deflate, gzip;q=0.5", "deflate", true},
+		{"/text", "*", "gzip", true},
+		{"/text", "br", "", true},
+		{"/text", "", "", true},
+		{"/text", "gzip;q=0", "", true},
+		{"/small", "gzip", "", true},
+		{"/image", "gzip", "", false},
+		{"/sniffed", "gzip", "gzip", true},
+	}
+
+	for _, test := range tests {
+		rec := compressRequest(s, test.path, test.accept)
+
+		if enc := rec.Header().Get("Content-Encoding"); enc != test.encoding {
+			t.Error("Wrong encoding for", test.path, test.accept, enc)
+		}
+		if vary := rec.Header().Get("Vary") == "Accept-Encoding"; vary != test.vary {
+			t.Error("Wrong Vary for", test.path, test.accept, rec.Header().Get("Vary"))
+		}
+		if rec.Code != http.StatusOK {
+			t.Error("Wrong status", rec.Code)
+		}
+
+		body := decompress(t, test.encoding, rec.Body.Bytes())
+		if test.path == "/small" && body != "{}" {
+			t.Error("Wrong body", body)
+		} else if test.path != "/small" && !strings.HasSuffix(body, compressBody) {
+			t.Error("Wrong body for", test.path, test.accept)
+		}
+	}
+}
+
+func TestCompressionHeaders(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(NewCompression(flate.BestSpeed))
+	s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Header().Set("Content-Length", "1900")
+		rw.Header().Set("ETag", `"abc"`)
+		rw.WriteHeader(http.StatusCreated)
+		io.WriteString(rw, compressBody)
+	})
+	s.Route("/encoded").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.Header().Set("Content-Encoding", "br")
+		io.WriteString(rw, compressBody)
+	})
+
+	rec := compressRequest(s, "/a", "gzip")
+	if rec.Code != http.StatusCreated {
+		t.Error("Status not kept", rec.Code)
+	}
+	if rec.Header().Get("Content-Length") != "" {
+		t.Error("Content-Length of uncompressed body sent")
+	}
+	if rec.Header().Get("ETag") != `W/"abc"` {
+		t.Error("ETag not weakened", rec.Header().Get("ETag"))
+	}
+
+	rec = compressRequest(s, "/encoded", "gzip")
+	if rec.Header().Get("Content-Encoding") != "br" || rec.Body.String() != compressBody {
+		t.Error("Encoded response compressed again", rec.Header())
+	}
+}
+
+func TestCompressionRouteOptOut(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(NewCompression(flate.DefaultCompression))
+	s.Route("/stream").Compression(false)
+	s.Route("/stream/events").Get(textHandler("text/plain", compressBody))
+	s.Route("/stream/compressed").Compression(true).Get(textHandler("text/plain", compressBody))
+
+	rec := compressRequest(s, "/stream/events", "gzip")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Error("Opted out route compressed", rec.Header())
+	}
+	if rec.Body.String() != compressBody {
+		t.Error("Wrong body")
+	}
+
+	rec = compressRequest(s, "/stream/compressed", "gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Route turning compression back on not compressed", rec.Header())
+	}
+}
+
+func TestCompressionFlush(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(NewCompression(flate.DefaultCompression))
+	s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		io.WriteString(rw, "first")
+		rw.(http.Flusher).Flush()
+
+		if rec := rw.(interface{ Unwrap() http.ResponseWriter }).Unwrap().(*httptest.ResponseRecorder); !rec.Flushed {
+			t.Error("Flush not passed on")
+		}
+		io.WriteString(rw, " second")
+	})
+
+	rec := compressRequest(s, "/a", "gzip")
+	if rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Flushed response not compressed", rec.Header())
+	}
+	if body := decompress(t, "gzip", rec.Body.Bytes()); body != "first second" {
+		t.Error("Wrong body", body)
+	}
+}
+
+func TestCompressionReadFrom(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Middleware(NewCompression(flate.DefaultCompression))
+	s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		if _, ok := rw.(http.Hijacker); !ok {
+			t.Error("Hijacker hidden")
+		}
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.(io.ReaderFrom).ReadFrom(strings.NewReader(compressBody))
+	})
+	s.Route("/small").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Content-Type", "text/plain")
+		rw.(io.ReaderFrom).ReadFrom(strings.NewReader("small"))
+	})
+
+	tests := []struct {
+		path     string
+		encoding string
+		body     string
+	}{
+		{"/a", "gzip", compressBody},
+		{"/small", "", "small"},
+	}
+
+	for _, test := range tests {
+		rec := compressRequest(s, test.path, "gzip")
+		if rec.Header().Get("Content-Encoding") != test.encoding {
+			t.Error("Wrong encoding for", test.path, rec.Header())
+		}
+		if body := decompress(t, test.encoding, rec.Body.Bytes()); body != test.body {
+			t.Error("Wrong body for", test.path, body)
+		}
+	}
+}
+
+func TestCompressionInvalidLevel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Invalid level accepted")
+		}
+	}()
+	NewCompression(42)
+}
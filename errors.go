@@ -0,0 +1,140 @@
+package powermux
+
+import (
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"net/http"
+)
+
+// HandlerE is a handler that returns its errors instead of writing them.
+//
+// Returned errors are passed to the error handlers of the route, then rendered as an RFC 7807 problem+json
+// response. HandlerE implements http.Handler, so it can be registered like any other handler:
+//
+//	mux.Route("/users/:id").Get(powermux.HandlerE(getUser))
+type HandlerE func(w http.ResponseWriter, r *http.Request) error
+
+// ServeHTTP calls h(w, r) and handles any error it returns.
+func (h HandlerE) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w := newResponseWriter(rw)
+	if err := h(w, req); err != nil {
+		handleError(w, req, err, writeProblem)
+	}
+}
+
+// ErrorHandler handles errors returned by handlers.
+type ErrorHandler interface {
+	// HandleError either writes a response for the error and returns nil, or returns an error,
+	// possibly a different one, for the next error handler to deal with.
+	HandleError(w http.ResponseWriter, r *http.Request, err error) error
+}
+
+// The ErrorHandlerFunc type is an adapter to allow the use of ordinary functions as ErrorHandlers.
+type ErrorHandlerFunc func(w http.ResponseWriter, r *http.Request, err error) error
+
+// HandleError calls f(w, r, err).
+func (f ErrorHandlerFunc) HandleError(w http.ResponseWriter, r *http.Request, err error) error {
+	return f(w, r, err)
+}
+
+// ErrorHandler adds an error handler to this route.
+// This handler will also be called for any routes further down the path from this point.
+//
+// Errors returned by HandlerE and JSON handlers are offered to the error handlers of the routes above them,
+// the deepest first, until one handles it. Error handlers can also translate errors, such as domain errors into
+// ones implementing StatusCoder, and pass them on. Unhandled errors are rendered by the handler's default.
+func (r *Route) ErrorHandler(h ErrorHandler) *Route {
+	r.errorHandlers = append(r.errorHandlers, h)
+	return r
+}
+
+// ErrorHandlerFunc registers a plain function as an error handler.
+func (r *Route) ErrorHandlerFunc(f ErrorHandlerFunc) *Route {
+	return r.ErrorHandler(f)
+}
+
+// handleError passes an error through the request's error handlers, leaving anything unhandled to final
+func handleError(rw http.ResponseWriter, req *http.Request, err error, final func(http.ResponseWriter, *http.Request, error)) {
+	if ex := getRequestExecution(req); ex != nil {
+		for i := len(ex.errorHandlers) - 1; i >= 0 && err != nil; i-- {
+			err = ex.errorHandlers[i].HandleError(rw, req, err)
+		}
+	}
+
+	if err != nil {
+		final(rw, req, err)
+	}
+}
+
+// Problem is an RFC 7807 problem details object. It can be returned as an error to control the response exactly.
+type Problem struct {
+	Type     string `json:"type,omitempty"`
+	Title    string `json:"title,omitempty"`
+	Status   int    `json:"status,omitempty"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+}
+
+// Error returns the detail of the problem, or its title if there are no details
+func (p *Problem) Error() string {
+	if p.Detail != "" {
+		return p.Detail
+	}
+	return p.Title
+}
+
+// StatusCode returns the status of the problem, 500 if it isn't set
+func (p *Problem) StatusCode() int {
+	if p.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return p.Status
+}
+
+// writeProblem renders an error as problem+json. Errors without a status code are logged and reported without
+// their message, as are those with a 5xx status.
+func writeProblem(rw http.ResponseWriter, req *http.Request, err error) {
+	problem := &Problem{}
+
+	var p *Problem
+	var sc StatusCoder
+	switch {
+	case errors.As(err, &p):
+		*problem = *p
+		problem.Status = p.StatusCode()
+	case errors.As(err, &sc):
+		problem.Status = sc.StatusCode()
+		problem.Detail = err.Error()
+	default:
+		problem.Status = http.StatusInternalServerError
+	}
+
+	if problem.Title == "" {
+		problem.Title = http.StatusText(problem.Status)
+	}
+
+	if problem.Status >= http.StatusInternalServerError {
+		slog.ErrorContext(req.Context(), "powermux: handler error",
+			slog.String("method", req.Method),
+			slog.String("path", req.URL.Path),
+			slog.String("route", RequestPath(req)),
+			slog.Int("status", problem.Status),
+			slog.String("error", err.Error()),
+		)
+		if p == nil {
+			problem.Detail = ""
+		}
+	}
+
+	// too late to change the response
+	if w, ok := rw.(*responseWriter); ok && w.Written() {
+		return
+	}
+
+	data, _ := json.Marshal(problem)
+	rw.Header().Set("Content-Type", "application/problem+json")
+	rw.Header().Del("Content-Length")
+	rw.WriteHeader(problem.Status)
+	rw.Write(append(data, '\n'))
+}
@@ -0,0 +1,181 @@
+package powermux
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+var errOutOfStock = errors.New("out of stock")
+
+func errorMux() *ServeMux {
+	s := NewServeMux()
+	s.Route("/items/:id").Get(HandlerE(func(w http.ResponseWriter, r *http.Request) error {
+		switch PathParam(r, "id") {
+		case "missing":
+			return ErrorWithStatus(http.StatusNotFound, errors.New("no such item"))
+		case "gone":
+			return &Problem{Type: "https://example.com/gone", Status: http.StatusGone, Detail: "item was deleted"}
+		case "broken":
+			return errors.New("connection refused")
+		case "sold":
+			return errOutOfStock
+		case "partial":
+			w.Write([]byte("partial"))
+			return errors.New("write failed")
+		}
+		w.Write([]byte("item"))
+		return nil
+	}))
+	return s
+}
+
+func TestHandlerE_Problems(t *testing.T) {
+	s := errorMux()
+
+	var logs bytes.Buffer
+	defaultLogger := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&logs, nil)))
+	defer slog.SetDefault(defaultLogger)
+
+	tests := []struct {
+		path     string
+		code     int
+		response string
+	}{
+		{"/items/1", http.StatusOK, "item"},
+		{"/items/missing", http.StatusNotFound, `{"title":"Not Found","status":404,"detail":"no such item"}` + "\n"},
+		{"/items/gone", http.StatusGone, `{"type":"https://example.com/gone","title":"Gone","status":410,"detail":"item was deleted"}` + "\n"},
+		{"/items/broken", http.StatusInternalServerError, `{"title":"Internal Server Error","status":500}` + "\n"},
+		{"/items/partial", http.StatusOK, "partial"},
+	}
+
+	for _, test := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, test.path, nil))
+		if rec.Code != test.code || rec.Body.String() != test.response {
+			t.Errorf("Wrong response for %s: %d %s", test.path, rec.Code, rec.Body.String())
+		}
+		if test.code >= 400 && rec.Header().Get("Content-Type") != "application/problem+json" {
+			t.Errorf("Wrong content type for %s: %s", test.path, rec.Header().Get("Content-Type"))
+		}
+	}
+
+	if !strings.Contains(logs.String(), "connection refused") || !strings.Contains(logs.String(), "route=/items/:id") {
+		t.Error("Server error not logged", logs.String())
+	}
+	if !strings.Contains(logs.String(), "write failed") {
+		t.Error("Error after writing not logged", logs.String())
+	}
+	if strings.Contains(logs.String(), "no such item") {
+		t.Error("Client error logged", logs.String())
+	}
+}
+
+func TestRoute_ErrorHandler(t *testing.T) {
+	s := errorMux()
+
+	var order []string
+	s.Route("/").ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) error {
+		order = append(order, "root")
+		if errors.Is(err, errOutOfStock) {
+			return ErrorWithStatus(http.StatusConflict, err)
+		}
+		return err
+	})
+	s.Route("/items").ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) error {
+		order = append(order, "items")
+		if strings.Contains(err.Error(), "refused") {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return nil
+		}
+		return err
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/sold", nil))
+	if rec.Code != http.StatusConflict || !strings.Contains(rec.Body.String(), `"detail":"out of stock"`) {
+		t.Error("Translated error not rendered", rec.Code, rec.Body.String())
+	}
+	if strings.Join(order, ",") != "items,root" {
+		t.Error("Error handlers called in the wrong order", order)
+	}
+
+	order = nil
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/broken", nil))
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.Len() != 0 {
+		t.Error("Handled error rendered", rec.Code, rec.Body.String())
+	}
+	if strings.Join(order, ",") != "items" {
+		t.Error("Error handled more than once", order)
+	}
+}
+
+func TestRoute_ErrorHandlerWithPathParams(t *testing.T) {
+	s := errorMux()
+	s.Route("/").
+		MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+			n(w, WithPathParams(r, map[string]string{"id": "broken"}))
+		}).
+		ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) error {
+			w.WriteHeader(http.StatusTeapot)
+			return nil
+		})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/items/1", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Error("Error handler lost by WithPathParams", rec.Code)
+	}
+}
+
+func TestRoute_ErrorHandlerMounted(t *testing.T) {
+	var order []string
+
+	inner := errorMux()
+	inner.Route("/items").ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) error {
+		order = append(order, "inner")
+		return err
+	})
+
+	s := NewServeMux()
+	s.Route("/shop").ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) error {
+		order = append(order, "outer")
+		w.WriteHeader(http.StatusTeapot)
+		return nil
+	}).MountMux(inner)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/shop/items/broken", nil))
+	if rec.Code != http.StatusTeapot {
+		t.Error("Mount's error handler not called", rec.Code)
+	}
+	if strings.Join(order, ",") != "inner,outer" {
+		t.Error("Error handlers called in the wrong order", order)
+	}
+}
+
+func TestRoute_ErrorHandlerJSON(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").ErrorHandlerFunc(func(w http.ResponseWriter, r *http.Request, err error) error {
+		if errors.Is(err, errOutOfStock) {
+			return ErrorWithStatus(http.StatusConflict, err)
+		}
+		return err
+	})
+	s.Route("/buy").Post(JSON(func(ctx context.Context, req struct{}) (struct{}, error) {
+		return struct{}{}, errOutOfStock
+	}))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/buy", nil))
+	if rec.Code != http.StatusConflict || rec.Body.String() != `{"error":"out of stock"}`+"\n" {
+		t.Error("JSON handler errors not passed to error handlers", rec.Code, rec.Body.String())
+	}
+}
@@ -3,7 +3,7 @@ package main
 import (
 	"context"
 	"github.com/Sirupsen/logrus"
-	"github.com/twinj/uuid"
+	"github.com/andrewburian/powermux"
 	"net/http"
 )
 
@@ -24,11 +24,11 @@ type logCtxKeyType string
 
 var logCtxKey = logCtxKeyType("event")
 
-// Injects a new log entry with a request UUID into the request context
+// Injects a new log entry with the request ID into the request context
 func (m *LoggerMiddleware) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(rw http.ResponseWriter, req *http.Request)) {
 
 	// inject the log into the context along with some info
-	entry := m.baseEntry.WithField("id", uuid.NewV4())
+	entry := m.baseEntry.WithField("id", powermux.RequestID(req))
 
 	req = req.WithContext(context.WithValue(req.Context(), logCtxKey, entry))
 
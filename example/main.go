@@ -17,7 +17,8 @@ func main() {
 		baseEntry: logrus.NewEntry(logrus.StandardLogger()).WithField("project", "Powermux-sample"),
 	}
 
-	// add the logging middleware
+	// give every request an ID, then add the logging middleware which uses it
+	mux.Route("/").Middleware(&powermux.RequestIDs{})
 	mux.Route("/").Middleware(logger)
 
 	// set up static resources like the database
@@ -7,17 +7,50 @@ import (
 
 // routeExecution is the complete instructions for running serve on a route
 type routeExecution struct {
-	pattern    string
-	params     map[string]string
-	notFound   http.Handler
-	middleware []Middleware
-	handler    http.Handler
+	pattern string
+	// the full pattern of the route this mux is mounted under
+	mountPrefix string
+	params      map[string]string
+	notFound    http.Handler
+	middleware  []Middleware
+	handler     http.Handler
+	// the request being routed
+	req *http.Request
+	// the generated response if handlers declined the request
+	rejection http.Handler
+	// the media type chosen by content negotiation
+	mediaType string
+	// the timeout of the deepest route that set one
+	timeout *routeTimeout
+	// the request body limit of the deepest route that set one
+	bodyLimit *routeBodyLimit
+	// the rate limit of the deepest route that declared one
+	rateLimit *routeRateLimit
+	// the policy of the deepest route that set one for the method
+	policy Policy
+	// the error handlers of every route crossed, the deepest last
+	errorHandlers []ErrorHandler
+	// the caching policy of the deepest route that set one for the method
+	cache *CachePolicy
+	// the compression setting of the deepest route that set one
+	compression *bool
+	// the writer the mux was given
+	writer http.ResponseWriter
+	// whether the handler holds on to the connection, streaming or upgrading it
+	streaming bool
+	// the ID given to the request by the RequestIDs middleware
+	requestID string
+	// the route reached, the method of the chosen handler and where it came from
+	route  *Route
+	method string
+	kind   HandlerKind
 }
 
 func newExecution() *routeExecution {
 	return &routeExecution{
-		middleware: make([]Middleware, 0),
-		params:     make(map[string]string),
+		middleware:    make([]Middleware, 0),
+		params:        make(map[string]string),
+		errorHandlers: make([]ErrorHandler, 0),
 	}
 }
 
@@ -28,6 +61,36 @@ func (ex *routeExecution) Reset() {
 	}
 	ex.handler = nil
 	ex.notFound = nil
+	ex.pattern = ""
+	ex.mountPrefix = ""
+	ex.req = nil
+	ex.rejection = nil
+	ex.mediaType = ""
+	ex.timeout = nil
+	ex.bodyLimit = nil
+	ex.rateLimit = nil
+	ex.policy = nil
+	ex.errorHandlers = ex.errorHandlers[0:0]
+	ex.cache = nil
+	ex.compression = nil
+	ex.requestID = ""
+	ex.writer = nil
+	ex.streaming = false
+	ex.route = nil
+	ex.method = ""
+	ex.kind = HandlerRegistered
+}
+
+// detach returns a copy of the execution that isn't returned to the pool with the original
+func (ex *routeExecution) detach() *routeExecution {
+	cp := *ex
+	cp.params = make(map[string]string, len(ex.params))
+	for key, value := range ex.params {
+		cp.params[key] = value
+	}
+	cp.middleware = append([]Middleware(nil), ex.middleware...)
+	cp.errorHandlers = append([]ErrorHandler(nil), ex.errorHandlers...)
+	return &cp
 }
 
 type executionPool struct {
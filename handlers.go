@@ -28,6 +28,28 @@ func (h methodNotAllowedHandler) ServeHTTP(w http.ResponseWriter, r *http.Reques
 	w.WriteHeader(http.StatusMethodNotAllowed)
 }
 
+type notAcceptableHandler []string
+
+// ServeHTTP responds with a Not Acceptable and lists the media types that can be produced.
+func (h notAcceptableHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Add("Vary", "Accept")
+	http.Error(w, "Supported media types: "+strings.Join(h, ", "), http.StatusNotAcceptable)
+}
+
+type unsupportedMediaTypeHandler []string
+
+// ServeHTTP responds with an Unsupported Media Type and lists the media types that are accepted.
+func (h unsupportedMediaTypeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// advertise the accepted types where a header is defined for it
+	switch r.Method {
+	case http.MethodPost:
+		w.Header().Set("Accept-Post", strings.Join(h, ", "))
+	case http.MethodPatch:
+		w.Header().Set("Accept-Patch", strings.Join(h, ", "))
+	}
+	http.Error(w, "Supported media types: "+strings.Join(h, ", "), http.StatusUnsupportedMediaType)
+}
+
 type defaultOptionsHandler struct {
 	methods []string
 }
@@ -43,6 +65,11 @@ func (r *Route) methodNotAllowed() http.Handler {
 			methods = append(methods, method)
 		}
 	}
+	for method := range r.variants {
+		if _, ok := r.handlers[method]; !ok && method != methodAny {
+			methods = append(methods, method)
+		}
+	}
 
 	// 405 only makes sense if some methods are allowed
 	if len(methods) > 0 {
@@ -0,0 +1,298 @@
+package powermux
+
+import (
+	"context"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"reflect"
+	"strconv"
+)
+
+// StatusCoder is implemented by errors and responses that decide their own HTTP status code.
+type StatusCoder interface {
+	StatusCode() int
+}
+
+// Validator is implemented by request types that can check themselves once bound.
+type Validator interface {
+	Validate() error
+}
+
+// statusError is an error with a status code
+type statusError struct {
+	code int
+	err  error
+}
+
+// ErrorWithStatus returns an error that responds with the given status code, wrapping err.
+func ErrorWithStatus(code int, err error) error {
+	return &statusError{
+		code: code,
+		err:  err,
+	}
+}
+
+// Error returns the wrapped error's message
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the wrapped error
+func (e *statusError) Unwrap() error {
+	return e.err
+}
+
+// StatusCode returns the status code of the error
+func (e *statusError) StatusCode() int {
+	return e.code
+}
+
+// JSON adapts a typed function to an http.Handler that decodes requests into In and encodes the returned Out.
+//
+// In is usually a struct. A JSON request body is decoded into it, then fields tagged with `path:"name"`,
+// `query:"name"` or `header:"Name"` are set from path parameters, query parameters and headers. Fields may be
+// strings, booleans, numbers, implementations of encoding.TextUnmarshaler, pointers to any of those, or slices of
+// them for repeated query parameters. If In implements Validator, it is validated before f is called.
+//
+// Out is encoded as JSON with a 200 OK, or the status given by Out's StatusCode method if it implements
+// StatusCoder. Errors are passed to the route's error handlers, and any left unhandled are sent as
+// {"error": "message"} with the status of the first StatusCoder in their chain, or a 500 Internal Server Error
+// without the message if there is none. Binding failures are 400 Bad Request, unsupported request bodies
+// 415 Unsupported Media Type, and validation failures 422 Unprocessable Entity.
+//
+//	mux.Route("/users/:id").Get(powermux.JSON(getUser))
+func JSON[In any, Out any](f func(ctx context.Context, req In) (Out, error)) http.Handler {
+	return &jsonHandler[In, Out]{
+		f: f,
+	}
+}
+
+// jsonHandler is the handler returned by JSON
+type jsonHandler[In any, Out any] struct {
+	f func(ctx context.Context, req In) (Out, error)
+}
+
+// ServeHTTP binds the request, calls the function and writes its result
+func (h *jsonHandler[In, Out]) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	var in In
+	if err := bind(req, &in); err != nil {
+		handleError(rw, req, err, writeJSONError)
+		return
+	}
+
+	if err := validate(&in); err != nil {
+		handleError(rw, req, withDefaultStatus(http.StatusUnprocessableEntity, err), writeJSONError)
+		return
+	}
+
+	out, err := h.f(req.Context(), in)
+	if err != nil {
+		handleError(rw, req, err, writeJSONError)
+		return
+	}
+
+	code := http.StatusOK
+	if sc, ok := interface{}(out).(StatusCoder); ok {
+		code = sc.StatusCode()
+	}
+	writeJSON(rw, code, out)
+}
+
+// validate validates a bound request, whether Validate is declared on the type or its pointer
+func validate(v interface{}) error {
+	if val, ok := reflect.ValueOf(v).Elem().Interface().(Validator); ok {
+		return val.Validate()
+	}
+	if val, ok := v.(Validator); ok {
+		return val.Validate()
+	}
+	return nil
+}
+
+// withDefaultStatus gives an error a status code unless it already has one
+func withDefaultStatus(code int, err error) error {
+	var sc StatusCoder
+	if errors.As(err, &sc) {
+		return err
+	}
+	return ErrorWithStatus(code, err)
+}
+
+// writeJSON encodes a value as the response
+func writeJSON(rw http.ResponseWriter, code int, v interface{}) {
+	if code == http.StatusNoContent || code == http.StatusNotModified {
+		rw.WriteHeader(code)
+		return
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		http.Error(rw, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+
+	rw.Header().Set("Content-Type", "application/json")
+	rw.WriteHeader(code)
+	rw.Write(append(data, '\n'))
+}
+
+// jsonError is the body of error responses
+type jsonError struct {
+	Error string `json:"error"`
+}
+
+// writeJSONError reports an error, hiding the message of errors without a status
+func writeJSONError(rw http.ResponseWriter, req *http.Request, err error) {
+	var sc StatusCoder
+	if !errors.As(err, &sc) {
+		writeJSON(rw, http.StatusInternalServerError, jsonError{http.StatusText(http.StatusInternalServerError)})
+		return
+	}
+	writeJSON(rw, sc.StatusCode(), jsonError{err.Error()})
+}
+
+// bind fills v from the request body, path parameters, query and headers
+func bind(req *http.Request, v interface{}) error {
+	if req.Body != nil && req.Body != http.NoBody && req.ContentLength != 0 {
+		mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type"))
+		if mediaType != "application/json" && mediaType != "" {
+			return ErrorWithStatus(http.StatusUnsupportedMediaType, fmt.Errorf("unsupported content type %q", mediaType))
+		}
+
+		if err := json.NewDecoder(req.Body).Decode(v); err != nil {
+			var maxErr *http.MaxBytesError
+			if errors.As(err, &maxErr) {
+				return ErrorWithStatus(http.StatusRequestEntityTooLarge, errors.New("request body too large"))
+			}
+			if !errors.Is(err, io.EOF) {
+				return ErrorWithStatus(http.StatusBadRequest, fmt.Errorf("invalid request body: %w", err))
+			}
+		}
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	for rv.Kind() == reflect.Pointer {
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		rv = rv.Elem()
+	}
+	if rv.Kind() != reflect.Struct {
+		return nil
+	}
+
+	query := req.URL.Query()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		field := rt.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		var values []string
+		var source, name string
+
+		if name = field.Tag.Get("path"); name != "" {
+			source = "path parameter"
+			if value, ok := PathParams(req)[name]; ok {
+				values = []string{value}
+			}
+		} else if name = field.Tag.Get("query"); name != "" {
+			source = "query parameter"
+			values = query[name]
+		} else if name = field.Tag.Get("header"); name != "" {
+			source = "header"
+			values = req.Header.Values(name)
+		} else {
+			continue
+		}
+
+		if len(values) == 0 {
+			continue
+		}
+
+		if err := setField(rv.Field(i), values); err != nil {
+			// the parser's name isn't useful to clients
+			var numErr *strconv.NumError
+			if errors.As(err, &numErr) {
+				err = numErr.Err
+			}
+			return ErrorWithStatus(http.StatusBadRequest, fmt.Errorf("invalid %s %q: %w", source, name, err))
+		}
+	}
+
+	return nil
+}
+
+var textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+
+// setField parses values into a field
+func setField(field reflect.Value, values []string) error {
+	if field.Kind() == reflect.Slice && !field.Type().Implements(textUnmarshalerType) &&
+		!reflect.PointerTo(field.Type()).Implements(textUnmarshalerType) {
+		slice := reflect.MakeSlice(field.Type(), len(values), len(values))
+		for i, value := range values {
+			if err := setValue(slice.Index(i), value); err != nil {
+				return err
+			}
+		}
+		field.Set(slice)
+		return nil
+	}
+
+	return setValue(field, values[0])
+}
+
+// setValue parses a string into a value
+func setValue(v reflect.Value, s string) error {
+	if v.Kind() == reflect.Pointer {
+		p := reflect.New(v.Type().Elem())
+		if err := setValue(p.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(p)
+		return nil
+	}
+
+	if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+		return u.UnmarshalText([]byte(s))
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	default:
+		return fmt.Errorf("unsupported field type %s", v.Type())
+	}
+	return nil
+}
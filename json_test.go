@@ -0,0 +1,128 @@
+package powermux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+type getUserRequest struct {
+	ID      int64     `path:"id"`
+	Fields  []string  `query:"field"`
+	Verbose *bool     `query:"verbose"`
+	Since   time.Time `query:"since"`
+	Tenant  string    `header:"X-Tenant"`
+}
+
+type createUserRequest struct {
+	Org  string `path:"org" json:"-"`
+	Name string `json:"name"`
+	Age  int    `json:"age"`
+}
+
+func (r createUserRequest) Validate() error {
+	if r.Name == "" {
+		return errors.New("name is required")
+	}
+	return nil
+}
+
+type user struct {
+	ID   int64  `json:"id"`
+	Name string `json:"name"`
+	Org  string `json:"org,omitempty"`
+}
+
+type createdUser struct {
+	user
+}
+
+func (createdUser) StatusCode() int {
+	return http.StatusCreated
+}
+
+var errUserNotFound = ErrorWithStatus(http.StatusNotFound, errors.New("user not found"))
+
+func jsonMux() *ServeMux {
+	s := NewServeMux()
+	s.Route("/users/:id").Get(JSON(func(ctx context.Context, req getUserRequest) (*user, error) {
+		if req.ID == 404 {
+			return nil, errUserNotFound
+		}
+		if req.ID == 500 {
+			return nil, errors.New("database password is hunter2")
+		}
+		name := req.Tenant + " " + strings.Join(req.Fields, ",") + " " + req.Since.Format("2006")
+		if req.Verbose != nil && *req.Verbose {
+			name += " verbose"
+		}
+		return &user{ID: req.ID, Name: name}, nil
+	}))
+	s.Route("/orgs/:org/users").Post(JSON(func(ctx context.Context, req createUserRequest) (createdUser, error) {
+		return createdUser{user{ID: 1, Name: req.Name, Org: req.Org}}, nil
+	}))
+	return s
+}
+
+func jsonRequest(s *ServeMux, method, target, contentType, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-Tenant", "acme")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestJSON_Binding(t *testing.T) {
+	s := jsonMux()
+
+	rec := jsonRequest(s, http.MethodGet, "/users/7?field=a&field=b&verbose=true&since=2024-01-02T00:00:00Z", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatal("Wrong status", rec.Code, rec.Body.String())
+	}
+	if rec.Body.String() != `{"id":7,"name":"acme a,b 2024 verbose"}`+"\n" {
+		t.Error("Wrong response", rec.Body.String())
+	}
+	if rec.Header().Get("Content-Type") != "application/json" {
+		t.Error("Wrong content type", rec.Header().Get("Content-Type"))
+	}
+
+	rec = jsonRequest(s, http.MethodPost, "/orgs/acme/users", "application/json", `{"name":"andrew","org":"evil"}`)
+	if rec.Code != http.StatusCreated || rec.Body.String() != `{"id":1,"name":"andrew","org":"acme"}`+"\n" {
+		t.Error("Wrong response", rec.Code, rec.Body.String())
+	}
+}
+
+func TestJSON_Errors(t *testing.T) {
+	s := jsonMux()
+
+	tests := []struct {
+		method      string
+		target      string
+		contentType string
+		body        string
+		code        int
+		response    string
+	}{
+		{http.MethodGet, "/users/abc", "", "", http.StatusBadRequest, `{"error":"invalid path parameter \"id\": invalid syntax"}`},
+		{http.MethodGet, "/users/1?verbose=maybe", "", "", http.StatusBadRequest, `{"error":"invalid query parameter \"verbose\": invalid syntax"}`},
+		{http.MethodGet, "/users/404", "", "", http.StatusNotFound, `{"error":"user not found"}`},
+		{http.MethodGet, "/users/500", "", "", http.StatusInternalServerError, `{"error":"Internal Server Error"}`},
+		{http.MethodPost, "/orgs/acme/users", "text/plain", "andrew", http.StatusUnsupportedMediaType, `{"error":"unsupported content type \"text/plain\""}`},
+		{http.MethodPost, "/orgs/acme/users", "application/json", "{", http.StatusBadRequest, `{"error":"invalid request body: unexpected EOF"}`},
+		{http.MethodPost, "/orgs/acme/users", "application/json", `{"age":3}`, http.StatusUnprocessableEntity, `{"error":"name is required"}`},
+	}
+
+	for _, test := range tests {
+		rec := jsonRequest(s, test.method, test.target, test.contentType, test.body)
+		if rec.Code != test.code || rec.Body.String() != test.response+"\n" {
+			t.Errorf("Wrong response for %s %s: %d %s", test.method, test.target, rec.Code, rec.Body.String())
+		}
+	}
+}
@@ -0,0 +1,94 @@
+package powermux
+
+import (
+	"net/http"
+	"net/textproto"
+)
+
+// Matcher decides whether a conditional handler applies to a request.
+type Matcher interface {
+	Match(*http.Request) bool
+}
+
+// The MatcherFunc type is an adapter to allow the use of ordinary functions as Matchers.
+type MatcherFunc func(*http.Request) bool
+
+// Match calls f(req).
+func (f MatcherFunc) Match(req *http.Request) bool {
+	return f(req)
+}
+
+// headerMatcher matches requests with a header, or a header with a specific value
+type headerMatcher struct {
+	name  string
+	value string
+}
+
+// Header returns a Matcher for requests with the named header set to value.
+// If value is empty, any request with the header present matches.
+func Header(name, value string) Matcher {
+	return headerMatcher{
+		name:  textproto.CanonicalMIMEHeaderKey(name),
+		value: value,
+	}
+}
+
+// Match checks all values of the header
+func (m headerMatcher) Match(req *http.Request) bool {
+	values, ok := req.Header[m.name]
+	if !ok {
+		return false
+	}
+	if m.value == "" {
+		return true
+	}
+	for _, v := range values {
+		if v == m.value {
+			return true
+		}
+	}
+	return false
+}
+
+// vary returns the header responses to matched requests depend on
+func (m headerMatcher) vary() string {
+	return m.name
+}
+
+// Query returns a Matcher for requests with the named query parameter set to value.
+// If value is empty, any request with the parameter present matches.
+func Query(name, value string) Matcher {
+	return MatcherFunc(func(req *http.Request) bool {
+		values, ok := req.URL.Query()[name]
+		if !ok {
+			return false
+		}
+		if value == "" {
+			return true
+		}
+		for _, v := range values {
+			if v == value {
+				return true
+			}
+		}
+		return false
+	})
+}
+
+// When restricts the most recently registered handler to requests matching all of the given matchers.
+//
+// Conditional handlers for the same method are evaluated in the order they were registered, falling back on
+// the unconditional handler for the method. If no handler for the method matches, the request is not found.
+//
+//	r.Get(v2Handler).When(powermux.Header("X-Api-Version", "2")).
+//		Get(v1Handler)
+func (r *Route) When(matchers ...Matcher) *Route {
+	v := r.variant("When")
+	for _, m := range matchers {
+		v.matchers = append(v.matchers, m)
+		if h, ok := m.(interface{ vary() string }); ok {
+			v.vary = append(v.vary, h.vary())
+		}
+	}
+	return r
+}
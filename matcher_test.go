@@ -0,0 +1,120 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoute_When(t *testing.T) {
+	s := NewServeMux()
+
+	betaHeader := MatcherFunc(func(r *http.Request) bool {
+		return r.Header.Get("X-Beta") == "yes"
+	})
+
+	s.Route("/users").
+		Get(dummyHandler("v2")).When(Header("X-Api-Version", "2")).
+		Get(dummyHandler("tenant")).When(Header("x-tenant", "")).
+		Get(dummyHandler("beta")).When(Query("beta", "true"), betaHeader).
+		Get(dummyHandler("default"))
+
+	tests := []struct {
+		target string
+		header http.Header
+		body   string
+	}{
+		{"/users", nil, "default"},
+		{"/users", http.Header{"X-Api-Version": {"2"}}, "v2"},
+		{"/users", http.Header{"X-Api-Version": {"1"}}, "default"},
+		{"/users", http.Header{"X-Api-Version": {"2"}, "X-Tenant": {"acme"}}, "v2"},
+		{"/users", http.Header{"X-Tenant": {"acme"}}, "tenant"},
+		{"/users?beta=true", nil, "default"},
+		{"/users?beta=true", http.Header{"X-Beta": {"yes"}}, "beta"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, test.target, nil)
+		for k, v := range test.header {
+			req.Header[k] = v
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Body.String() != test.body {
+			t.Errorf("Wrong handler for %s %v: %s", test.target, test.header, rec.Body.String())
+		}
+	}
+}
+
+func TestRoute_WhenVary(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").Get(rightHandler).When(Header("X-Api-Version", "2"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Api-Version", "2")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Header().Get("Vary") != "X-Api-Version" {
+		t.Error("Vary header not set", rec.Header().Get("Vary"))
+	}
+}
+
+func TestRoute_WhenNoMatch(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/users").
+		Get(rightHandler).When(Header("X-Api-Version", "2")).
+		Post(rightHandler)
+
+	// the method exists but not for this request
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Error("Wrong status for unmatched conditions", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodHead, "/users", nil))
+
+	if rec.Code != http.StatusNotFound {
+		t.Error("Wrong status for unmatched HEAD", rec.Code)
+	}
+
+	// the method doesn't exist at all
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodDelete, "/users", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error("Wrong status for unhandled method", rec.Code)
+	}
+}
+
+func TestRoute_WhenWithProduces(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").
+		Get(dummyHandler("v2 json")).When(Header("X-Api-Version", "2")).Produces("application/json").
+		Get(dummyHandler("json")).Produces("application/json")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Api-Version", "2")
+	req.Header.Set("Accept", "text/csv")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Error("Wrong status", rec.Code)
+	}
+
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "v2 json" {
+		t.Error("Wrong handler", rec.Body.String())
+	}
+}
@@ -0,0 +1,74 @@
+package powermux
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// Meta attaches a metadata value to this route, replacing any value for the same key.
+//
+// Metadata lets middleware be configured per route, such as marking routes for auditing, and is available from
+// GetMeta, and from requests through RouteMeta and RouteInfo. It is also shown by ServeMux.String.
+func (r *Route) Meta(key string, value interface{}) *Route {
+	r.meta[key] = value
+	delete(r.inheritedMeta, key)
+	return r
+}
+
+// MetaInherited attaches a metadata value to this route and every route below it.
+// Routes below can replace the value with Meta or MetaInherited.
+func (r *Route) MetaInherited(key string, value interface{}) *Route {
+	r.meta[key] = value
+	r.inheritedMeta[key] = value
+	return r
+}
+
+// GetMeta returns the metadata value for key attached to this route, or inherited from a route above it.
+// It returns nil if there is none.
+func (r *Route) GetMeta(key string) interface{} {
+	if value, ok := r.meta[key]; ok {
+		return value
+	}
+	for parent := r.parent; parent != nil; parent = parent.parent {
+		if value, ok := parent.inheritedMeta[key]; ok {
+			return value
+		}
+	}
+	return nil
+}
+
+// allMeta returns every metadata value of this route, including inherited ones
+func (r *Route) allMeta() map[string]interface{} {
+	all := make(map[string]interface{})
+	for parent := r.parent; parent != nil; parent = parent.parent {
+		for key, value := range parent.inheritedMeta {
+			if _, ok := all[key]; !ok {
+				all[key] = value
+			}
+		}
+	}
+	for key, value := range r.meta {
+		all[key] = value
+	}
+	return all
+}
+
+// metaString describes the metadata of a route for route listings
+func (r *Route) metaString() string {
+	all := r.allMeta()
+
+	entries := make([]string, 0, len(all))
+	for key, value := range all {
+		entries = append(entries, fmt.Sprintf("%s=%v", key, value))
+	}
+	sort.Strings(entries)
+
+	return strings.Join(entries, ", ")
+}
+
+// RouteMeta returns the metadata value for key of the route the request was routed to, or nil if there is none.
+func RouteMeta(req *http.Request, key string) interface{} {
+	return RouteInfo(req).Meta(key)
+}
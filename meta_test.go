@@ -0,0 +1,73 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRoute_Meta(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/api").MetaInherited("owner", "platform").Meta("internal", true)
+	s.Route("/api/users").MetaInherited("owner", "identity")
+	users := s.Route("/api/users/:id").Meta("audit", true)
+	billing := s.Route("/api/billing")
+	api := s.Route("/api")
+
+	tests := []struct {
+		route    *Route
+		key      string
+		expected interface{}
+	}{
+		{api, "owner", "platform"},
+		{api, "internal", true},
+		{billing, "owner", "platform"},
+		{billing, "internal", nil},
+		{users, "owner", "identity"},
+		{users, "audit", true},
+		{s.Route("/other"), "owner", nil},
+	}
+
+	for _, test := range tests {
+		if value := test.route.GetMeta(test.key); value != test.expected {
+			t.Errorf("Wrong %s for %s: %v", test.key, test.route.fullPath, value)
+		}
+	}
+
+	// a plain value stops inheritance
+	s.Route("/api/users").Meta("owner", "legacy")
+	if value := users.GetMeta("owner"); value != "platform" {
+		t.Error("Replaced value still inherited", value)
+	}
+}
+
+func TestRouteMeta(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/admin").MetaInherited("audit", "admin")
+	s.Route("/admin/users/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		if audit, ok := RouteMeta(r, "audit").(string); ok {
+			w.Write([]byte(audit))
+		}
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/users/1", nil))
+	if rec.Body.String() != "admin" {
+		t.Error("Inherited metadata not available from the request", rec.Body.String())
+	}
+
+	if value := RouteMeta(httptest.NewRequest(http.MethodGet, "/", nil), "audit"); value != nil {
+		t.Error("Metadata outside a mux", value)
+	}
+}
+
+func TestRoute_MetaString(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/api").MetaInherited("owner", "platform")
+	s.Route("/api/users/:id").Meta("audit", true).Get(dummyHandler("user"))
+
+	if !strings.Contains(s.String(), "/api/users/:id\t[GET]\t{audit=true, owner=platform}\n") {
+		t.Error("Metadata not listed", s.String())
+	}
+}
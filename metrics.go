@@ -0,0 +1,234 @@
+package powermux
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultMetricsBuckets are the latency histogram bucket boundaries in seconds used when none are given.
+var DefaultMetricsBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// metricMethods are the methods used as labels, anything else is counted as OTHER
+var metricMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodConnect: true,
+	http.MethodOptions: true,
+	http.MethodTrace:   true,
+}
+
+// routeKey identifies a route pattern and method
+type routeKey struct {
+	method string
+	path   string
+}
+
+// statusKey identifies a route pattern, method and response status
+type statusKey struct {
+	routeKey
+	status int
+}
+
+// requestMetrics are the totals kept for each statusKey
+type requestMetrics struct {
+	count   uint64
+	bytes   int64
+	sum     float64
+	buckets []uint64
+}
+
+// Metrics is a middleware that records request counts, in flight requests, response sizes and latencies.
+//
+// Requests are labelled by method, status and the route pattern from RequestPath rather than the raw
+// request path, so the number of series is bounded by the number of routes.
+//
+// Metrics is also an http.Handler that renders everything it has recorded in the Prometheus text
+// exposition format, and can be registered on a route such as "/metrics".
+type Metrics struct {
+	buckets  []float64
+	lock     sync.Mutex
+	requests map[statusKey]*requestMetrics
+	inFlight map[routeKey]int64
+}
+
+// NewMetrics creates a Metrics middleware with the given latency histogram buckets in seconds.
+// DefaultMetricsBuckets are used if none are given.
+func NewMetrics(buckets ...float64) *Metrics {
+	if len(buckets) == 0 {
+		buckets = DefaultMetricsBuckets
+	}
+
+	sorted := make([]float64, len(buckets))
+	copy(sorted, buckets)
+	sort.Float64s(sorted)
+
+	return &Metrics{
+		buckets:  sorted,
+		requests: make(map[statusKey]*requestMetrics),
+		inFlight: make(map[routeKey]int64),
+	}
+}
+
+// ServeHTTPMiddleware records metrics for the rest of the chain.
+func (m *Metrics) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	key := routeKey{
+		method: req.Method,
+		path:   RequestPath(req),
+	}
+	if !metricMethods[key.method] {
+		key.method = "OTHER"
+	}
+
+	m.lock.Lock()
+	m.inFlight[key]++
+	m.lock.Unlock()
+
+	w := newResponseWriter(rw)
+	start := time.Now()
+
+	// record even if the handler panics
+	completed := false
+	defer func() {
+		status := w.Status()
+		if !completed && !w.Written() {
+			status = http.StatusInternalServerError
+		}
+		m.observe(key, status, w.Size(), time.Since(start))
+	}()
+
+	next(w, req)
+	completed = true
+}
+
+// observe records a finished request
+func (m *Metrics) observe(key routeKey, status int, size int64, d time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	m.inFlight[key]--
+
+	sk := statusKey{routeKey: key, status: status}
+	rm, ok := m.requests[sk]
+	if !ok {
+		rm = &requestMetrics{
+			buckets: make([]uint64, len(m.buckets)),
+		}
+		m.requests[sk] = rm
+	}
+
+	seconds := d.Seconds()
+	rm.count++
+	rm.bytes += size
+	rm.sum += seconds
+
+	// buckets are stored non-cumulatively and summed when rendered
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			rm.buckets[i]++
+			break
+		}
+	}
+}
+
+// ServeHTTP renders the recorded metrics in the Prometheus text exposition format.
+func (m *Metrics) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	rw.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	w := bufio.NewWriter(rw)
+	m.writeTo(w)
+	w.Flush()
+}
+
+// writeTo writes all metrics in a stable order
+func (m *Metrics) writeTo(w *bufio.Writer) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	statusKeys := make([]statusKey, 0, len(m.requests))
+	for k := range m.requests {
+		statusKeys = append(statusKeys, k)
+	}
+	sort.Slice(statusKeys, func(i, j int) bool {
+		a, b := statusKeys[i], statusKeys[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+
+	routeKeys := make([]routeKey, 0, len(m.inFlight))
+	for k := range m.inFlight {
+		routeKeys = append(routeKeys, k)
+	}
+	sort.Slice(routeKeys, func(i, j int) bool {
+		a, b := routeKeys[i], routeKeys[j]
+		if a.path != b.path {
+			return a.path < b.path
+		}
+		return a.method < b.method
+	})
+
+	w.WriteString("# HELP powermux_requests_total Total number of HTTP requests served.\n")
+	w.WriteString("# TYPE powermux_requests_total counter\n")
+	for _, k := range statusKeys {
+		fmt.Fprintf(w, "powermux_requests_total{%s} %d\n", k.labels(), m.requests[k].count)
+	}
+
+	w.WriteString("# HELP powermux_requests_in_flight Number of HTTP requests currently being served.\n")
+	w.WriteString("# TYPE powermux_requests_in_flight gauge\n")
+	for _, k := range routeKeys {
+		fmt.Fprintf(w, "powermux_requests_in_flight{%s} %d\n", k.labels(), m.inFlight[k])
+	}
+
+	w.WriteString("# HELP powermux_response_size_bytes_total Total number of response body bytes sent.\n")
+	w.WriteString("# TYPE powermux_response_size_bytes_total counter\n")
+	for _, k := range statusKeys {
+		fmt.Fprintf(w, "powermux_response_size_bytes_total{%s} %d\n", k.labels(), m.requests[k].bytes)
+	}
+
+	w.WriteString("# HELP powermux_request_duration_seconds Latency of HTTP requests in seconds.\n")
+	w.WriteString("# TYPE powermux_request_duration_seconds histogram\n")
+	for _, k := range statusKeys {
+		rm := m.requests[k]
+		labels := k.labels()
+
+		var cumulative uint64
+		for i, bound := range m.buckets {
+			cumulative += rm.buckets[i]
+			fmt.Fprintf(w, "powermux_request_duration_seconds_bucket{%s,le=\"%s\"} %d\n",
+				labels, strconv.FormatFloat(bound, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(w, "powermux_request_duration_seconds_bucket{%s,le=\"+Inf\"} %d\n", labels, rm.count)
+		fmt.Fprintf(w, "powermux_request_duration_seconds_sum{%s} %s\n", labels, strconv.FormatFloat(rm.sum, 'g', -1, 64))
+		fmt.Fprintf(w, "powermux_request_duration_seconds_count{%s} %d\n", labels, rm.count)
+	}
+}
+
+// labels formats the route labels
+func (k routeKey) labels() string {
+	return fmt.Sprintf("method=\"%s\",path=\"%s\"", escapeLabel(k.method), escapeLabel(k.path))
+}
+
+// labels formats the route and status labels
+func (k statusKey) labels() string {
+	return fmt.Sprintf("%s,status=\"%d\"", k.routeKey.labels(), k.status)
+}
+
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// escapeLabel escapes a label value for the text exposition format
+func escapeLabel(v string) string {
+	return labelEscaper.Replace(v)
+}
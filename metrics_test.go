@@ -0,0 +1,97 @@
+package powermux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics_Exposition(t *testing.T) {
+	s := NewServeMux()
+	m := NewMetrics(0.1, 1)
+
+	s.Route("/").Middleware(m)
+	s.Route("/metrics").Get(m)
+	s.Route("/users/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "hello")
+	})
+
+	for _, path := range []string{"/users/andrew", "/users/jim", "/nothing"} {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain; version=0.0.4") {
+		t.Error("Wrong content type", rec.Header().Get("Content-Type"))
+	}
+
+	body := rec.Body.String()
+	t.Logf("Metrics:\n%s", body)
+
+	expected := []string{
+		"# TYPE powermux_requests_total counter\n",
+		`powermux_requests_total{method="GET",path="/users/:id",status="200"} 2` + "\n",
+		`powermux_requests_total{method="GET",path="",status="404"} 1` + "\n",
+		`powermux_response_size_bytes_total{method="GET",path="/users/:id",status="200"} 10` + "\n",
+		`powermux_requests_in_flight{method="GET",path="/users/:id"} 0` + "\n",
+		`powermux_requests_in_flight{method="GET",path="/metrics"} 1` + "\n",
+		`powermux_request_duration_seconds_bucket{method="GET",path="/users/:id",status="200",le="0.1"} 2` + "\n",
+		`powermux_request_duration_seconds_bucket{method="GET",path="/users/:id",status="200",le="+Inf"} 2` + "\n",
+		`powermux_request_duration_seconds_count{method="GET",path="/users/:id",status="200"} 2` + "\n",
+	}
+
+	for _, line := range expected {
+		if !strings.Contains(body, line) {
+			t.Error("Missing line", line)
+		}
+	}
+
+	if strings.Contains(body, "andrew") {
+		t.Error("Raw path used as a label")
+	}
+}
+
+func TestMetrics_Panic(t *testing.T) {
+	s := NewServeMux()
+	m := NewMetrics()
+
+	s.Route("/").
+		Middleware(&Recovery{Logger: PanicLoggerFunc(func(*http.Request, interface{}, []byte) {})}).
+		Middleware(m).
+		Get(panicHandler("boom"))
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.Contains(rec.Body.String(), `powermux_requests_total{method="GET",path="/",status="500"} 1`) {
+		t.Error("Panic not counted as a server error")
+	}
+}
+
+func TestMetrics_MethodLabel(t *testing.T) {
+	s := NewServeMux()
+	m := NewMetrics()
+
+	s.Route("/").Middleware(m).Any(rightHandler)
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("BREW", "/", nil))
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.Contains(rec.Body.String(), `method="OTHER"`) || strings.Contains(rec.Body.String(), "BREW") {
+		t.Error("Unknown method not folded into OTHER")
+	}
+}
+
+func TestEscapeLabel(t *testing.T) {
+	if v := escapeLabel("a\"b\\c\nd"); v != `a\"b\\c\nd` {
+		t.Error("Wrong escaping", v)
+	}
+}
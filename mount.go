@@ -0,0 +1,148 @@
+package powermux
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type mountCtxKeyType string
+
+var mountCtxKey = mountCtxKeyType("mount")
+
+// mountPoint is what a mounted ServeMux inherits from the route it is mounted under
+type mountPoint struct {
+	prefix string
+	params map[string]string
+	// the error handlers of the routes above the mount, the deepest last
+	errorHandlers []ErrorHandler
+}
+
+// mountHandler serves a handler with the path of the route it's mounted on removed from the request
+type mountHandler struct {
+	handler http.Handler
+	// the route pattern being removed
+	prefix string
+	// the number of path segments in the prefix
+	depth int
+}
+
+// Mount registers a handler for this route and every path below it, for any method.
+//
+// The handler sees the request path with this route's path removed, so a handler mounted on "/admin" receiving
+// a request for "/admin/users" will see "/users". Middleware on this route and those above it still runs.
+//
+// If the handler is a ServeMux, its path parameters are combined with this route's and RequestPath returns
+// the full pattern, as it does for MountMux. Errors its routes don't handle are passed on to the error
+// handlers of this route and those above it.
+func (r *Route) Mount(h http.Handler) *Route {
+	m := &mountHandler{
+		handler: h,
+		prefix:  r.fullPath,
+		depth:   strings.Count(r.fullPath, "/"),
+	}
+
+	r.Route("/*").Any(m)
+	return r.Any(m)
+}
+
+// MountMux registers another ServeMux to handle this route and every path below it.
+//
+// The mounted mux routes the remainder of the path, and RequestPath returns the combined pattern of both
+// muxes, such as "/admin/users/:id". Path parameters from this route are available to the mounted mux's handlers.
+func (r *Route) MountMux(s *ServeMux) *Route {
+	return r.Mount(s)
+}
+
+// ServeHTTP strips the mount prefix and passes the request on
+func (m *mountHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	// include the prefixes of any muxes this one is itself mounted under
+	prefix := m.prefix
+	var errorHandlers []ErrorHandler
+	if ex := getRequestExecution(req); ex != nil {
+		prefix = ex.mountPrefix + prefix
+		errorHandlers = ex.errorHandlers
+	}
+
+	serveMounted(m.handler, rw, req, m.depth, &mountPoint{
+		prefix:        prefix,
+		params:        PathParams(req),
+		errorHandlers: errorHandlers,
+	})
+}
+
+// serveMounted removes depth leading segments from the request path and serves it with h,
+// letting a ServeMux know where it is mounted
+func serveMounted(h http.Handler, rw http.ResponseWriter, req *http.Request, depth int, mount *mountPoint) {
+	remainder := trimSegments(req.URL.EscapedPath(), depth)
+
+	// shallow copy like http.StripPrefix
+	r2 := new(http.Request)
+	*r2 = *req
+	r2.URL = new(url.URL)
+	*r2.URL = *req.URL
+
+	if unescaped, err := url.PathUnescape(remainder); err == nil {
+		r2.URL.Path = unescaped
+	} else {
+		r2.URL.Path = remainder
+	}
+	if r2.URL.Path != remainder {
+		r2.URL.RawPath = remainder
+	} else {
+		r2.URL.RawPath = ""
+	}
+
+	r2 = r2.WithContext(context.WithValue(req.Context(), mountCtxKey, mount))
+
+	h.ServeHTTP(rw, r2)
+}
+
+// trimSegments removes depth leading segments from path, returning the remainder with a leading slash
+func trimSegments(path string, depth int) string {
+	index := 0
+	for i := 0; i < depth; i++ {
+		next := strings.IndexByte(path[index+1:], '/')
+		if next < 0 {
+			return "/"
+		}
+		index += next + 1
+	}
+	return path[index:]
+}
+
+// getMountPoint returns the mount point a request was routed through, or nil if there is none
+func getMountPoint(req *http.Request) *mountPoint {
+	mount, _ := req.Context().Value(mountCtxKey).(*mountPoint)
+	return mount
+}
+
+// mount combines an execution with the route it is mounted under
+func (ex *routeExecution) mount(m *mountPoint) {
+	ex.mountPrefix = m.prefix
+
+	// not found executions keep their empty pattern
+	if ex.pattern == "/" {
+		ex.pattern = m.prefix
+		if ex.pattern == "" {
+			ex.pattern = "/"
+		}
+	} else if ex.pattern != "" {
+		ex.pattern = m.prefix + ex.pattern
+	}
+
+	// our own parameters take precedence
+	for k, v := range m.params {
+		if _, ok := ex.params[k]; !ok {
+			ex.params[k] = v
+		}
+	}
+
+	// errors the mounted routes don't handle go on to the routes above the mount
+	if len(m.errorHandlers) > 0 {
+		handlers := make([]ErrorHandler, 0, len(m.errorHandlers)+len(ex.errorHandlers))
+		handlers = append(handlers, m.errorHandlers...)
+		ex.errorHandlers = append(handlers, ex.errorHandlers...)
+	}
+}
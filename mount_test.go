@@ -0,0 +1,156 @@
+package powermux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRoute_Mount(t *testing.T) {
+	s := NewServeMux()
+
+	var path, rawPath string
+
+	s.Route("/admin").Mount(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		rawPath = r.URL.RawPath
+	}))
+
+	tests := map[string]string{
+		"/admin":            "/",
+		"/admin/users":      "/users",
+		"/admin/users/list": "/users/list",
+	}
+
+	for reqPath, expected := range tests {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, reqPath, nil))
+		if path != expected {
+			t.Errorf("Wrong path for %s. Expected %s, got %s", reqPath, expected, path)
+		}
+	}
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/admin/a%2Fb/c", nil))
+	if path != "/a/b/c" || rawPath != "/a%2Fb/c" {
+		t.Error("Escaped path not preserved", path, rawPath)
+	}
+}
+
+func TestRoute_MountParentMiddleware(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").Middleware(mid1)
+	s.Route("/admin").Middleware(mid2).Mount(rightHandler)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/x", nil))
+
+	if rec.Body.String() != "mid1mid2right" {
+		t.Error("Parent middleware not run", rec.Body.String())
+	}
+}
+
+func TestRoute_MountMux(t *testing.T) {
+	child := NewServeMux()
+
+	var pattern, org, id, path string
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern = RequestPath(r)
+		org = PathParam(r, "org")
+		id = PathParam(r, "id")
+		path = r.URL.Path
+	})
+
+	child.Route("/").Get(handler)
+	child.Route("/users/:id").Get(handler)
+	child.NotFound(dummyHandler("child not found"))
+
+	parent := NewServeMux()
+	parent.Route("/orgs/:org/admin").MountMux(child)
+
+	parent.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orgs/acme/admin/users/andrew", nil))
+
+	if pattern != "/orgs/:org/admin/users/:id" {
+		t.Error("Wrong combined pattern", pattern)
+	}
+	if org != "acme" || id != "andrew" {
+		t.Error("Wrong params", org, id)
+	}
+	if path != "/users/andrew" {
+		t.Error("Wrong path", path)
+	}
+
+	parent.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/orgs/acme/admin", nil))
+
+	if pattern != "/orgs/:org/admin" {
+		t.Error("Wrong combined pattern for mount root", pattern)
+	}
+
+	rec := httptest.NewRecorder()
+	parent.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/orgs/acme/admin/nothing", nil))
+
+	if rec.Body.String() != "child not found" {
+		t.Error("Mounted mux not found handler not used", rec.Body.String())
+	}
+}
+
+func TestRoute_MountMuxRoot(t *testing.T) {
+	child := NewServeMux()
+
+	var pattern string
+
+	child.Route("/a").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern = RequestPath(r)
+		io.WriteString(w, "a")
+	})
+
+	parent := NewServeMux()
+	parent.Route("/").MountMux(child)
+
+	rec := httptest.NewRecorder()
+	parent.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/a", nil))
+
+	if rec.Body.String() != "a" || pattern != "/a" {
+		t.Error("Root mount failed", rec.Body.String(), pattern)
+	}
+}
+
+func TestRoute_MountMuxNested(t *testing.T) {
+	var pattern, org, id, path string
+
+	c := NewServeMux()
+	c.Route("/items/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern = RequestPath(r)
+		org = PathParam(r, "org")
+		id = PathParam(r, "id")
+		path = r.URL.Path
+	})
+	c.Route("/").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		pattern = RequestPath(r)
+	})
+
+	b := NewServeMux()
+	b.Route("/b").MountMux(c)
+
+	a := NewServeMux()
+	a.Route("/a/:org").MountMux(b)
+
+	a.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/acme/b/items/7", nil))
+
+	if pattern != "/a/:org/b/items/:id" {
+		t.Error("Outer mount prefix dropped", pattern)
+	}
+	if org != "acme" || id != "7" {
+		t.Error("Wrong params", org, id)
+	}
+	if path != "/items/7" {
+		t.Error("Wrong path", path)
+	}
+
+	a.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/a/acme/b", nil))
+
+	if pattern != "/a/:org/b" {
+		t.Error("Wrong pattern for nested mount root", pattern)
+	}
+}
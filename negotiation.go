@@ -0,0 +1,264 @@
+package powermux
+
+import (
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// registration is a handler registered for a method, kept so conditions can be applied to it afterwards
+type registration struct {
+	method      string
+	handler     http.Handler
+	previous    http.Handler
+	hadPrevious bool
+	variant     *handlerVariant
+}
+
+// handlerVariant is a handler for a method that only applies to some requests
+type handlerVariant struct {
+	handler  http.Handler
+	produces []string
+	consumes []string
+	matchers []Matcher
+	// the protocol requests must ask to switch to, if this is an upgrade handler
+	upgrade string
+	// request headers the choice of this variant depends on
+	vary []string
+}
+
+// ServeHTTP marks the request headers the response depends on and calls the handler
+func (v *handlerVariant) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if len(v.produces) > 0 {
+		w.Header().Add("Vary", "Accept")
+	}
+	for _, h := range v.vary {
+		w.Header().Add("Vary", h)
+	}
+	if v.upgrade != "" {
+		w = hijackable(w, r)
+	}
+	v.handler.ServeHTTP(w, r)
+}
+
+// matches returns whether all the variant's matchers accept the request
+func (v *handlerVariant) matches(req *http.Request) bool {
+	if len(v.matchers) == 0 {
+		return true
+	}
+	if req == nil {
+		return false
+	}
+	for _, m := range v.matchers {
+		if !m.Match(req) {
+			return false
+		}
+	}
+	return true
+}
+
+// variant returns the variant of the most recently registered handler, turning it from the unconditional
+// handler for its method into a conditional one and restoring whatever it replaced
+func (r *Route) variant(caller string) *handlerVariant {
+	if r.last == nil {
+		panic("powermux: " + caller + " called before registering a method handler")
+	}
+
+	if r.last.variant == nil {
+		v := &handlerVariant{
+			handler: r.last.handler,
+		}
+		r.last.variant = v
+
+		if r.last.hadPrevious {
+			r.handlers[r.last.method] = r.last.previous
+		} else {
+			delete(r.handlers, r.last.method)
+		}
+
+		r.variants[r.last.method] = append(r.variants[r.last.method], v)
+	}
+
+	return r.last.variant
+}
+
+// Produces restricts the most recently registered handler to requests that accept one of the given media types.
+//
+// Several handlers may be registered for the same method with different media types, and the one the Accept
+// header gives the highest quality is used. If none are acceptable and no unconditional handler is registered for
+// the method, a 406 Not Acceptable response listing the supported types is sent.
+//
+//	r.Get(jsonHandler).Produces("application/json").
+//		Get(csvHandler).Produces("text/csv")
+func (r *Route) Produces(mediaTypes ...string) *Route {
+	v := r.variant("Produces")
+	for _, t := range mediaTypes {
+		v.produces = append(v.produces, strings.ToLower(t))
+	}
+	return r
+}
+
+// Consumes restricts the most recently registered handler to requests with a Content-Type matching one of the
+// given media types, which may be ranges such as "text/*".
+//
+// If no handler for the method accepts the request's Content-Type and no unconditional handler is registered,
+// a 415 Unsupported Media Type response listing the supported types is sent.
+func (r *Route) Consumes(mediaTypes ...string) *Route {
+	v := r.variant("Consumes")
+	for _, t := range mediaTypes {
+		v.consumes = append(v.consumes, strings.ToLower(t))
+	}
+	return r
+}
+
+// NegotiatedType returns the media type chosen from a handler's Produces list for the request, or an empty string
+// if there was no negotiation.
+func NegotiatedType(req *http.Request) string {
+	ex := getRequestExecution(req)
+	if ex == nil {
+		return ""
+	}
+	return ex.mediaType
+}
+
+// negotiate selects the variant best suited to the request. Variants whose matchers don't apply are skipped,
+// and among the rest the one producing the most acceptable type is chosen, the earliest registered winning ties.
+// If the remaining variants all decline the request, the response explaining why is saved in the execution
+// unless an earlier one was.
+func negotiate(variants []*handlerVariant, ex *routeExecution) *handlerVariant {
+	var accept []qualityValue
+	contentType := ""
+
+	if ex.req != nil {
+		if h := ex.req.Header.Get("Accept"); h != "" {
+			accept = parseQualityList(h)
+		}
+		contentType, _, _ = mime.ParseMediaType(ex.req.Header.Get("Content-Type"))
+	}
+
+	var best *handlerVariant
+	bestQuality := 0.0
+	bestType := ""
+	matched := false
+	consumable := false
+	var upgrades []string
+
+	for _, v := range variants {
+		if v.upgrade != "" && !upgradesTo(ex.req, v.upgrade) {
+			upgrades = append(upgrades, v.upgrade)
+			continue
+		}
+		if !v.matches(ex.req) {
+			continue
+		}
+		matched = true
+
+		if len(v.consumes) > 0 && !matchesAnyMediaRange(v.consumes, contentType) {
+			continue
+		}
+		consumable = true
+
+		// a handler without a type list produces anything
+		if len(v.produces) == 0 {
+			if bestQuality < 1 {
+				best, bestQuality, bestType = v, 1, ""
+			}
+			continue
+		}
+
+		for _, t := range v.produces {
+			if q := mediaQuality(accept, t); q > bestQuality {
+				best, bestQuality, bestType = v, q, t
+			}
+		}
+	}
+
+	if best != nil {
+		ex.mediaType = bestType
+		return best
+	}
+
+	// nothing here for this request, unless it should have asked to switch protocols
+	if !matched {
+		if len(upgrades) > 0 && ex.rejection == nil {
+			ex.rejection = upgradeRequiredHandler(upgrades)
+		}
+		return nil
+	}
+
+	if ex.rejection == nil {
+		if !consumable {
+			ex.rejection = unsupportedMediaTypeHandler(supportedTypes(variants, ex.req, func(v *handlerVariant) []string {
+				return v.consumes
+			}))
+		} else {
+			ex.rejection = notAcceptableHandler(supportedTypes(variants, ex.req, func(v *handlerVariant) []string {
+				return v.produces
+			}))
+		}
+	}
+
+	return nil
+}
+
+// supportedTypes lists the distinct media types of the variants that match the request
+func supportedTypes(variants []*handlerVariant, req *http.Request, types func(*handlerVariant) []string) []string {
+	seen := make(map[string]bool)
+	list := make([]string, 0, len(variants))
+	for _, v := range variants {
+		if !v.matches(req) {
+			continue
+		}
+		for _, t := range types(v) {
+			if !seen[t] {
+				seen[t] = true
+				list = append(list, t)
+			}
+		}
+	}
+	return list
+}
+
+// mediaQuality returns the quality given to a media type by the most specific matching range of an
+// Accept header. A missing Accept header accepts everything.
+func mediaQuality(accept []qualityValue, mediaType string) float64 {
+	if accept == nil {
+		return 1
+	}
+
+	quality := 0.0
+	specificity := 0
+	for _, qv := range accept {
+		if s := mediaRangeSpecificity(qv.value, mediaType); s > specificity {
+			quality, specificity = qv.q, s
+		}
+	}
+	return quality
+}
+
+// mediaRangeSpecificity returns how specifically a media range such as "text/*" matches a media type,
+// or 0 if it doesn't match
+func mediaRangeSpecificity(mediaRange, mediaType string) int {
+	switch {
+	case mediaRange == mediaType:
+		return 3
+	case mediaRange == "*/*":
+		return 1
+	case strings.HasSuffix(mediaRange, "/*") && strings.HasPrefix(mediaType, mediaRange[:len(mediaRange)-1]):
+		return 2
+	}
+	return 0
+}
+
+// matchesAnyMediaRange returns whether the media type matches any of the ranges
+func matchesAnyMediaRange(ranges []string, mediaType string) bool {
+	if mediaType == "" {
+		return false
+	}
+	for _, mediaRange := range ranges {
+		if mediaRangeSpecificity(mediaRange, mediaType) > 0 {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,195 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func negotiationMux() *ServeMux {
+	s := NewServeMux()
+
+	s.Route("/report").
+		Get(dummyHandler("json")).Produces("application/json").
+		Get(dummyHandler("csv")).Produces("text/csv").
+		Post(dummyHandler("post json")).Consumes("application/json").
+		Post(dummyHandler("post text")).Consumes("text/*")
+
+	return s
+}
+
+func negotiationRequest(s *ServeMux, method string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, "/report", nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoute_Produces(t *testing.T) {
+	s := negotiationMux()
+
+	tests := map[string]string{
+		"":                                      "json",
+		"text/csv":                              "csv",
+		"application/json;q=0.5, text/csv":      "csv",
+		"application/json, text/csv;q=0.9":      "json",
+		"text/*":                                "csv",
+		"*/*;q=0.1, text/csv;q=0":               "json",
+		"application/xml, application/json;q=1": "json",
+	}
+
+	for accept, body := range tests {
+		header := http.Header{}
+		if accept != "" {
+			header.Set("Accept", accept)
+		}
+		rec := negotiationRequest(s, http.MethodGet, header)
+		if rec.Body.String() != body {
+			t.Errorf("Wrong handler for Accept %q: %s", accept, rec.Body.String())
+		}
+	}
+
+	rec := negotiationRequest(s, http.MethodGet, nil)
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Error("Vary header not set")
+	}
+}
+
+func TestRoute_ProducesNotAcceptable(t *testing.T) {
+	s := negotiationMux()
+
+	rec := negotiationRequest(s, http.MethodGet, http.Header{"Accept": {"application/xml"}})
+
+	if rec.Code != http.StatusNotAcceptable {
+		t.Fatal("Wrong status", rec.Code)
+	}
+
+	if !strings.Contains(rec.Body.String(), "application/json, text/csv") {
+		t.Error("Supported types not listed", rec.Body.String())
+	}
+}
+
+func TestRoute_ProducesFallback(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").
+		Get(dummyHandler("default")).
+		Get(dummyHandler("csv")).Produces("text/csv")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/xml")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "default" {
+		t.Error("Unconditional handler not used", rec.Body.String())
+	}
+
+	req.Header.Set("Accept", "text/csv")
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "csv" {
+		t.Error("Conditional handler not preferred", rec.Body.String())
+	}
+}
+
+func TestRoute_ProducesAny(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").
+		Get(dummyHandler("csv")).Produces("text/csv").
+		Any(dummyHandler("any"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/xml")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Body.String() != "any" {
+		t.Error("Any handler not used as a fallback", rec.Body.String())
+	}
+}
+
+func TestRoute_Consumes(t *testing.T) {
+	s := negotiationMux()
+
+	tests := map[string]string{
+		"application/json":                "post json",
+		"application/json; charset=utf-8": "post json",
+		"text/plain":                      "post text",
+	}
+
+	for contentType, body := range tests {
+		rec := negotiationRequest(s, http.MethodPost, http.Header{"Content-Type": {contentType}})
+		if rec.Body.String() != body {
+			t.Errorf("Wrong handler for Content-Type %q: %s", contentType, rec.Body.String())
+		}
+	}
+
+	rec := negotiationRequest(s, http.MethodPost, http.Header{"Content-Type": {"application/xml"}})
+	if rec.Code != http.StatusUnsupportedMediaType {
+		t.Fatal("Wrong status", rec.Code)
+	}
+
+	if rec.Header().Get("Accept-Post") != "application/json, text/*" {
+		t.Error("Accepted types not advertised", rec.Header().Get("Accept-Post"))
+	}
+}
+
+func TestRoute_NegotiationMethods(t *testing.T) {
+	s := negotiationMux()
+
+	rec := negotiationRequest(s, http.MethodDelete, nil)
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatal("Wrong status", rec.Code)
+	}
+
+	allow := rec.Header().Get("Allow")
+	if !strings.Contains(allow, http.MethodGet) || !strings.Contains(allow, http.MethodPost) {
+		t.Error("Conditional methods not allowed", allow)
+	}
+
+	rec = negotiationRequest(s, http.MethodHead, http.Header{"Accept": {"text/csv"}})
+	if rec.Code != http.StatusOK {
+		t.Error("HEAD not negotiated against GET handlers", rec.Code)
+	}
+
+	routes := s.String()
+	if !strings.Contains(routes, "GET") || !strings.Contains(routes, "POST") {
+		t.Error("Conditional methods missing from String", routes)
+	}
+}
+
+func TestNegotiatedType(t *testing.T) {
+	s := NewServeMux()
+
+	var chosen string
+	s.Route("/").
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {
+			chosen = NegotiatedType(r)
+		}).
+		Produces("application/json", "application/xml")
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Accept", "application/xml, application/json;q=0.8")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if chosen != "application/xml" {
+		t.Error("Wrong negotiated type", chosen)
+	}
+}
+
+func TestRoute_ProducesWithoutHandler(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Produces without a handler didn't panic")
+		}
+	}()
+
+	newRoute().Produces("text/plain")
+}
@@ -0,0 +1,190 @@
+package powermux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// ProxyOptions configures a proxy route. The zero value is a usable configuration.
+type ProxyOptions struct {
+	// Transport makes the upstream requests. If nil, http.DefaultTransport is used.
+	Transport http.RoundTripper
+
+	// Timeout limits how long the upstream request may take, including reading the response body.
+	// Zero means no limit beyond the request's own context.
+	Timeout time.Duration
+
+	// PreserveHost sends the incoming Host header upstream instead of the target's host.
+	PreserveHost bool
+
+	// TrustForwarded keeps any X-Forwarded headers sent by the client, appending to X-Forwarded-For.
+	// This should only be set when powermux is behind another trusted proxy.
+	TrustForwarded bool
+
+	// FlushInterval is passed on to httputil.ReverseProxy.
+	FlushInterval time.Duration
+
+	// ModifyResponse is passed on to httputil.ReverseProxy.
+	ModifyResponse func(*http.Response) error
+
+	// ErrorHandler handles errors reaching the upstream. If nil, timeouts are answered with
+	// 504 Gateway Timeout and other errors with 502 Bad Gateway.
+	ErrorHandler func(http.ResponseWriter, *http.Request, error)
+}
+
+// proxyHandler forwards requests to a target built from the route's path parameters
+type proxyHandler struct {
+	target *url.URL
+	// the escaped target path split on '/'
+	segments []string
+	// the number of route path segments before the wildcard, or -1 if the route has none
+	depth   int
+	timeout time.Duration
+	proxy   *httputil.ReverseProxy
+}
+
+// Proxy registers a reverse proxy for any method sent to this route.
+//
+// The target is a URL template whose path may reference path parameters, and for wildcard routes the remainder
+// of the path with '*'. For example, Route("/users/:id/*").Proxy("http://users-svc/v2/:id/*", nil) sends
+// "/users/andrew/posts/1" to "http://users-svc/v2/andrew/posts/1". The query string of the request is appended
+// to any query in the target.
+//
+// X-Forwarded-For, X-Forwarded-Host and X-Forwarded-Proto are set on upstream requests, as is the traceparent
+// of any span started by the Tracing middleware. Proxy panics if target isn't an absolute URL.
+func (r *Route) Proxy(target string, opts *ProxyOptions) *Route {
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		panic("powermux: invalid proxy target " + target)
+	}
+
+	if opts == nil {
+		opts = &ProxyOptions{}
+	}
+
+	h := &proxyHandler{
+		target:   u,
+		segments: strings.Split(u.EscapedPath(), "/"),
+		depth:    -1,
+		timeout:  opts.Timeout,
+	}
+
+	if r.isWildcard {
+		h.depth = strings.Count(r.fullPath, "/") - 1
+	}
+
+	h.proxy = &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			h.rewrite(pr, opts)
+		},
+		Transport:      opts.Transport,
+		FlushInterval:  opts.FlushInterval,
+		ModifyResponse: opts.ModifyResponse,
+		ErrorHandler:   opts.ErrorHandler,
+	}
+
+	if h.proxy.ErrorHandler == nil {
+		h.proxy.ErrorHandler = proxyErrorHandler
+	}
+
+	return r.Any(h)
+}
+
+// ServeHTTP applies the timeout and forwards the request
+func (h *proxyHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	if h.timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), h.timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+
+	h.proxy.ServeHTTP(rw, req)
+}
+
+// rewrite builds the upstream request
+func (h *proxyHandler) rewrite(pr *httputil.ProxyRequest, opts *ProxyOptions) {
+	escaped := h.targetPath(pr.In)
+
+	out := pr.Out.URL
+	out.Scheme = h.target.Scheme
+	out.Host = h.target.Host
+	out.Path, _ = url.PathUnescape(escaped)
+	out.RawPath = ""
+	if out.EscapedPath() != escaped {
+		out.RawPath = escaped
+	}
+
+	switch {
+	case h.target.RawQuery == "":
+		out.RawQuery = pr.In.URL.RawQuery
+	case pr.In.URL.RawQuery == "":
+		out.RawQuery = h.target.RawQuery
+	default:
+		out.RawQuery = h.target.RawQuery + "&" + pr.In.URL.RawQuery
+	}
+
+	if opts.PreserveHost {
+		pr.Out.Host = pr.In.Host
+	} else {
+		pr.Out.Host = ""
+	}
+
+	pr.SetXForwarded()
+
+	// the proxy strips forwarding headers from the client, restore them if they're trusted
+	if opts.TrustForwarded {
+		if prior := pr.In.Header["X-Forwarded-For"]; len(prior) > 0 {
+			pr.Out.Header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+pr.Out.Header.Get("X-Forwarded-For"))
+		}
+		if host := pr.In.Header.Get("X-Forwarded-Host"); host != "" {
+			pr.Out.Header.Set("X-Forwarded-Host", host)
+		}
+		if proto := pr.In.Header.Get("X-Forwarded-Proto"); proto != "" {
+			pr.Out.Header.Set("X-Forwarded-Proto", proto)
+		}
+	}
+
+	InjectTraceContext(pr.In.Context(), pr.Out.Header)
+}
+
+// targetPath substitutes the path parameters and wildcard remainder into the target path
+func (h *proxyHandler) targetPath(req *http.Request) string {
+	remainder := ""
+	if h.depth >= 0 {
+		remainder = strings.TrimPrefix(trimSegments(req.URL.EscapedPath(), h.depth), "/")
+	}
+
+	parts := make([]string, 0, len(h.segments))
+	for _, segment := range h.segments {
+		switch {
+		case segment == "*":
+			if remainder != "" {
+				parts = append(parts, remainder)
+			}
+		case strings.HasPrefix(segment, ":"):
+			parts = append(parts, url.PathEscape(PathParam(req, segment[1:])))
+		default:
+			parts = append(parts, segment)
+		}
+	}
+
+	path := strings.Join(parts, "/")
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// proxyErrorHandler reports upstream failures as gateway errors
+func proxyErrorHandler(rw http.ResponseWriter, req *http.Request, err error) {
+	if errors.Is(err, context.DeadlineExceeded) {
+		rw.WriteHeader(http.StatusGatewayTimeout)
+		return
+	}
+	rw.WriteHeader(http.StatusBadGateway)
+}
@@ -0,0 +1,155 @@
+package powermux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// echoBackend responds with the request URI it received
+func echoBackend(t *testing.T, seen *http.Request) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*seen = *r
+		io.WriteString(w, r.URL.RequestURI())
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestRoute_Proxy(t *testing.T) {
+	var seen http.Request
+	backend := echoBackend(t, &seen)
+
+	s := NewServeMux()
+	s.Route("/users/:id").Proxy(backend.URL+"/v2/:id?source=mux", nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/users/an%2Fdrew?page=2", nil)
+	req.RemoteAddr = "192.0.2.7:1234"
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatal("Wrong status", rec.Code)
+	}
+
+	if rec.Body.String() != "/v2/an%2Fdrew?source=mux&page=2" {
+		t.Error("Wrong upstream URI", rec.Body.String())
+	}
+
+	if seen.Header.Get("X-Forwarded-For") != "192.0.2.7" {
+		t.Error("Wrong X-Forwarded-For", seen.Header.Get("X-Forwarded-For"))
+	}
+
+	if seen.Header.Get("X-Forwarded-Host") != "example.com" {
+		t.Error("Wrong X-Forwarded-Host", seen.Header.Get("X-Forwarded-Host"))
+	}
+
+	if seen.Header.Get("X-Forwarded-Proto") != "http" {
+		t.Error("Wrong X-Forwarded-Proto", seen.Header.Get("X-Forwarded-Proto"))
+	}
+
+	if seen.Host == "example.com" {
+		t.Error("Host should be the target's")
+	}
+}
+
+func TestRoute_ProxyWildcard(t *testing.T) {
+	var seen http.Request
+	backend := echoBackend(t, &seen)
+
+	s := NewServeMux()
+	s.Route("/files/:bucket/*").Proxy(backend.URL+"/store/:bucket/*", nil)
+
+	tests := map[string]string{
+		"/files/b1/a/b.txt": "/store/b1/a/b.txt",
+		"/files/b1/a%2Fb":   "/store/b1/a%2Fb",
+	}
+
+	for path, expected := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Body.String() != expected {
+			t.Errorf("Wrong upstream path for %s. Expected %s, got %s", path, expected, rec.Body.String())
+		}
+	}
+}
+
+func TestRoute_ProxyOptions(t *testing.T) {
+	var seen http.Request
+	backend := echoBackend(t, &seen)
+
+	s := NewServeMux()
+	s.Route("/").Proxy(backend.URL, &ProxyOptions{
+		PreserveHost:   true,
+		TrustForwarded: true,
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.7:1234"
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if seen.Host != "example.com" {
+		t.Error("Host not preserved", seen.Host)
+	}
+
+	if seen.Header.Get("X-Forwarded-For") != "198.51.100.1, 192.0.2.7" {
+		t.Error("Wrong X-Forwarded-For", seen.Header.Get("X-Forwarded-For"))
+	}
+
+	if seen.Header.Get("X-Forwarded-Proto") != "https" {
+		t.Error("Wrong X-Forwarded-Proto", seen.Header.Get("X-Forwarded-Proto"))
+	}
+}
+
+func TestRoute_ProxyTimeout(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	s := NewServeMux()
+	s.Route("/slow").Proxy(backend.URL, &ProxyOptions{Timeout: 10 * time.Millisecond})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow", nil))
+
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Error("Wrong status for timeout", rec.Code)
+	}
+}
+
+func TestRoute_ProxyUnreachable(t *testing.T) {
+	backend := httptest.NewServer(http.NotFoundHandler())
+	backend.Close()
+
+	s := NewServeMux()
+	s.Route("/").Proxy(backend.URL, nil)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	if rec.Code != http.StatusBadGateway {
+		t.Error("Wrong status for unreachable backend", rec.Code)
+	}
+}
+
+func TestRoute_ProxyInvalidTarget(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("Relative target accepted")
+		}
+	}()
+
+	NewServeMux().Route("/").Proxy("/relative", nil)
+}
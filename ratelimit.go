@@ -0,0 +1,339 @@
+package powermux
+
+import (
+	"context"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RateLimit is a token bucket allowing Requests requests every Per, in bursts of up to Burst requests.
+type RateLimit struct {
+	Requests int
+	Per      time.Duration
+	// Burst is the capacity of the bucket, the same as Requests if zero
+	Burst int
+}
+
+// capacity returns the number of tokens a full bucket holds
+func (l RateLimit) capacity() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// rate returns the number of tokens added to a bucket each second
+func (l RateLimit) rate() float64 {
+	return float64(l.Requests) / l.Per.Seconds()
+}
+
+// enabled returns whether the limit restricts anything
+func (l RateLimit) enabled() bool {
+	return l.Requests > 0 && l.Per > 0
+}
+
+// RateLimitResult is the state of a bucket after taking a token from it.
+type RateLimitResult struct {
+	// Allowed is set if there was a token for the request
+	Allowed bool
+	// Limit is the capacity of the bucket
+	Limit int
+	// Remaining is the number of whole tokens left in the bucket
+	Remaining int
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is how long until the next token is available, if the request wasn't allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore holds the token buckets of a RateLimiter.
+// Implementations must be safe for concurrent use.
+type RateLimitStore interface {
+	// Take removes a token from the bucket for key, creating a full bucket if there isn't one
+	Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error)
+}
+
+// tokenBucket is the state of a bucket in a MemoryRateLimitStore
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+	limit  RateLimit
+}
+
+// MemoryRateLimitStore is a RateLimitStore holding buckets in memory, suitable for a single server.
+type MemoryRateLimitStore struct {
+	lock    sync.Mutex
+	buckets map[string]*tokenBucket
+	swept   time.Time
+	now     func() time.Time
+}
+
+// NewMemoryRateLimitStore creates an empty in memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets: make(map[string]*tokenBucket),
+		now:     time.Now,
+	}
+}
+
+// Take removes a token from the bucket for key
+func (s *MemoryRateLimitStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	now := s.now()
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok || b.limit != limit {
+		b = &tokenBucket{
+			tokens: float64(limit.capacity()),
+			last:   now,
+			limit:  limit,
+		}
+		s.buckets[key] = b
+	}
+
+	capacity := float64(limit.capacity())
+	rate := limit.rate()
+
+	b.tokens = math.Min(capacity, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	result := RateLimitResult{
+		Limit: limit.capacity(),
+	}
+
+	if b.tokens >= 1 {
+		b.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+
+	result.Remaining = int(b.tokens)
+	result.Reset = seconds((capacity - b.tokens) / rate)
+	return result, nil
+}
+
+// sweep removes buckets that have refilled, at most once a minute
+func (s *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(s.swept) < time.Minute {
+		return
+	}
+	s.swept = now
+
+	for key, b := range s.buckets {
+		refill := seconds((float64(b.limit.capacity()) - b.tokens) / b.limit.rate())
+		if now.Sub(b.last) >= refill {
+			delete(s.buckets, key)
+		}
+	}
+}
+
+// seconds converts a number of seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// RateLimitKey returns the identity of the client or resource a request is counted against.
+type RateLimitKey func(req *http.Request) string
+
+// RateLimitByRoute counts requests against the route pattern they matched.
+func RateLimitByRoute(req *http.Request) string {
+	return RequestPath(req)
+}
+
+// RateLimitByMethod counts requests against their method.
+func RateLimitByMethod(req *http.Request) string {
+	return req.Method
+}
+
+// RateLimitByClientIP counts requests against the IP address of the client.
+//
+// The address is taken from the connection unless it belongs to one of the trusted proxies, given as
+// addresses or CIDR ranges, in which case X-Forwarded-For is followed back to the first untrusted address.
+// RateLimitByClientIP panics if a trusted proxy can't be parsed.
+func RateLimitByClientIP(trustedProxies ...string) RateLimitKey {
+	trusted := make([]netip.Prefix, 0, len(trustedProxies))
+	for _, proxy := range trustedProxies {
+		prefix, err := netip.ParsePrefix(proxy)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(proxy)
+			if addrErr != nil {
+				panic("powermux: invalid trusted proxy " + proxy)
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		trusted = append(trusted, prefix.Masked())
+	}
+
+	return func(req *http.Request) string {
+		return clientIP(req, trusted)
+	}
+}
+
+// RateLimitKeys combines several keys, counting requests against each distinct combination.
+func RateLimitKeys(keys ...RateLimitKey) RateLimitKey {
+	return func(req *http.Request) string {
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			parts[i] = key(req)
+		}
+		return strings.Join(parts, "|")
+	}
+}
+
+// clientIP returns the address of the client, trusting X-Forwarded-For only when sent by a trusted proxy
+func clientIP(req *http.Request, trusted []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(req.RemoteAddr)
+	if err != nil {
+		host = req.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isTrusted(addr, trusted) {
+		return host
+	}
+
+	// walk back through the proxies, the last address added is the most trustworthy
+	forwarded := strings.Split(strings.Join(req.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(forwarded[i])
+		hopAddr, err := netip.ParseAddr(hop)
+		if err != nil {
+			break
+		}
+		host = hopAddr.String()
+		if !isTrusted(hopAddr, trusted) {
+			break
+		}
+	}
+
+	return host
+}
+
+// isTrusted returns whether the address is in any of the trusted ranges
+func isTrusted(addr netip.Addr, trusted []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trusted {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// routeRateLimit is the rate limit declared on a route
+type routeRateLimit struct {
+	limit RateLimit
+	// the route declaring the limit, which has its own buckets
+	scope string
+}
+
+// RateLimit declares the rate limit for this route and every route below it, replacing the default limit of
+// the RateLimiter middleware handling the request. Requests to routes sharing a declared limit share buckets.
+// Host specific routes have their own buckets, even if their patterns match those of another host.
+//
+// Routes below this one can declare their own limit, or remove it by passing the zero RateLimit.
+// Declared limits have no effect unless a RateLimiter is installed above the route.
+func (r *Route) RateLimit(limit RateLimit) *Route {
+	r.rateLimit = &routeRateLimit{
+		limit: limit,
+		scope: r.hostname() + r.fullPath + " ",
+	}
+	return r
+}
+
+// hostname returns the host this route is specific to, or an empty string if it applies to every host
+func (r *Route) hostname() string {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r.host
+}
+
+// RateLimiter is a middleware that limits request rates with token buckets.
+//
+// Each request takes a token from the bucket for its key, by default the client's IP address, and is rejected
+// with a 429 Too Many Requests once the bucket is empty. Responses carry RateLimit-Limit, RateLimit-Remaining,
+// RateLimit-Reset and RateLimit-Policy headers, and rejected ones a Retry-After header.
+//
+// If the store fails, requests are allowed.
+type RateLimiter struct {
+	limit RateLimit
+	store RateLimitStore
+
+	// Key returns the key a request is counted against. If nil, RateLimitByClientIP() is used.
+	Key RateLimitKey
+
+	// Handler writes the response to rejected requests, in place of a plain 429 Too Many Requests.
+	Handler http.Handler
+}
+
+// NewRateLimiter creates a RateLimiter applying limit to routes that don't declare their own.
+// If store is nil, buckets are kept in memory.
+func NewRateLimiter(limit RateLimit, store RateLimitStore) *RateLimiter {
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	return &RateLimiter{
+		limit: limit,
+		store: store,
+	}
+}
+
+var defaultRateLimitKey = RateLimitByClientIP()
+
+// ServeHTTPMiddleware takes a token for the request, rejecting it if there are none
+func (l *RateLimiter) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	limit, scope := l.limit, ""
+	if ex := getRequestExecution(req); ex != nil && ex.rateLimit != nil {
+		limit, scope = ex.rateLimit.limit, ex.rateLimit.scope
+	}
+
+	if !limit.enabled() {
+		next(rw, req)
+		return
+	}
+
+	key := l.Key
+	if key == nil {
+		key = defaultRateLimitKey
+	}
+
+	result, err := l.store.Take(req.Context(), scope+key(req), limit)
+	if err != nil {
+		next(rw, req)
+		return
+	}
+
+	h := rw.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", ceilSeconds(result.Reset))
+	h.Set("RateLimit-Policy", strconv.Itoa(limit.Requests)+";w="+ceilSeconds(limit.Per))
+
+	if result.Allowed {
+		next(rw, req)
+		return
+	}
+
+	h.Set("Retry-After", ceilSeconds(result.RetryAfter))
+	if l.Handler != nil {
+		l.Handler.ServeHTTP(rw, req)
+		return
+	}
+	http.Error(rw, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+}
+
+// ceilSeconds formats a duration as a whole number of seconds, rounding up
+func ceilSeconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
@@ -0,0 +1,230 @@
+package powermux
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock is a controllable time source
+type fakeClock struct {
+	t time.Time
+}
+
+func (c *fakeClock) now() time.Time {
+	return c.t
+}
+
+func newTestRateLimiter(limit RateLimit) (*RateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.now
+	return NewRateLimiter(limit, store), clock
+}
+
+func rateLimitRequest(s *ServeMux, method, path, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiter(t *testing.T) {
+	limiter, clock := newTestRateLimiter(RateLimit{Requests: 2, Per: time.Minute})
+
+	s := NewServeMux()
+	s.Route("/").Middleware(limiter)
+	s.Route("/items").Get(dummyHandler("items"))
+
+	for i := 0; i < 2; i++ {
+		rec := rateLimitRequest(s, http.MethodGet, "/items", "10.0.0.1:1234")
+		if rec.Code != http.StatusOK {
+			t.Fatal("Request within the limit rejected", rec.Code)
+		}
+	}
+
+	rec := rateLimitRequest(s, http.MethodGet, "/items", "10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Error("Request over the limit allowed", rec.Code)
+	}
+
+	h := rec.Header()
+	if h.Get("RateLimit-Limit") != "2" || h.Get("RateLimit-Remaining") != "0" || h.Get("RateLimit-Reset") != "60" {
+		t.Error("Wrong rate limit headers", h)
+	}
+	if h.Get("RateLimit-Policy") != "2;w=60" {
+		t.Error("Wrong policy header", h.Get("RateLimit-Policy"))
+	}
+	if h.Get("Retry-After") != "30" {
+		t.Error("Wrong Retry-After", h.Get("Retry-After"))
+	}
+
+	// other clients have their own bucket
+	if rec := rateLimitRequest(s, http.MethodGet, "/items", "10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Error("Other client limited", rec.Code)
+	}
+
+	// tokens are refilled over time
+	clock.t = clock.t.Add(30 * time.Second)
+	rec = rateLimitRequest(s, http.MethodGet, "/items", "10.0.0.1:1234")
+	if rec.Code != http.StatusOK {
+		t.Error("Refilled bucket rejected request", rec.Code)
+	}
+	if rec.Header().Get("Retry-After") != "" {
+		t.Error("Retry-After sent on allowed request")
+	}
+}
+
+func TestRateLimiter_RouteLimits(t *testing.T) {
+	limiter, _ := newTestRateLimiter(RateLimit{Requests: 1, Per: time.Minute})
+
+	s := NewServeMux()
+	s.Route("/").Middleware(limiter)
+	s.Route("/a").Get(dummyHandler("a"))
+	s.Route("/b").Get(dummyHandler("b"))
+	s.Route("/search").RateLimit(RateLimit{Requests: 3, Per: time.Minute})
+	s.Route("/search/:index").Get(dummyHandler("search"))
+	s.Route("/health").RateLimit(RateLimit{}).Get(dummyHandler("health"))
+
+	// the default limit is shared by routes that don't declare one
+	rateLimitRequest(s, http.MethodGet, "/a", "10.0.0.1:1")
+	if rec := rateLimitRequest(s, http.MethodGet, "/b", "10.0.0.1:1"); rec.Code != http.StatusTooManyRequests {
+		t.Error("Default limit not shared", rec.Code)
+	}
+
+	// declared limits are inherited and have their own buckets
+	for i := 0; i < 3; i++ {
+		if rec := rateLimitRequest(s, http.MethodGet, "/search/users", "10.0.0.1:1"); rec.Code != http.StatusOK {
+			t.Fatal("Route limit not applied", rec.Code)
+		}
+	}
+	if rec := rateLimitRequest(s, http.MethodGet, "/search/posts", "10.0.0.1:1"); rec.Code != http.StatusTooManyRequests {
+		t.Error("Route limit not enforced", rec.Code)
+	}
+
+	// zero limits disable limiting
+	for i := 0; i < 5; i++ {
+		rec := rateLimitRequest(s, http.MethodGet, "/health", "10.0.0.1:1")
+		if rec.Code != http.StatusOK || rec.Header().Get("RateLimit-Limit") != "" {
+			t.Fatal("Disabled limit applied", rec.Code)
+		}
+	}
+}
+
+func TestRateLimiter_Keys(t *testing.T) {
+	limiter, _ := newTestRateLimiter(RateLimit{Requests: 1, Per: time.Minute})
+	limiter.Key = RateLimitKeys(RateLimitByMethod, RateLimitByRoute)
+	limiter.Handler = dummyHandler("slow down")
+
+	s := NewServeMux()
+	s.Route("/").Middleware(limiter)
+	s.Route("/users/:id").Get(dummyHandler("get")).Post(dummyHandler("post"))
+
+	rateLimitRequest(s, http.MethodGet, "/users/1", "10.0.0.1:1")
+
+	if rec := rateLimitRequest(s, http.MethodPost, "/users/1", "10.0.0.1:1"); rec.Code != http.StatusOK {
+		t.Error("Method not part of the key", rec.Code)
+	}
+
+	rec := rateLimitRequest(s, http.MethodGet, "/users/2", "10.0.0.2:1")
+	if rec.Body.String() != "slow down" {
+		t.Error("Route pattern not used as key", rec.Body.String())
+	}
+}
+
+func TestRateLimitByClientIP(t *testing.T) {
+	key := RateLimitByClientIP("10.0.0.0/8", "192.168.1.1")
+
+	tests := []struct {
+		remoteAddr string
+		forwarded  string
+		expected   string
+	}{
+		{"203.0.113.5:1234", "198.51.100.1", "203.0.113.5"},
+		{"10.1.2.3:1234", "198.51.100.1", "198.51.100.1"},
+		{"10.1.2.3:1234", "198.51.100.1, 198.51.100.2, 192.168.1.1", "198.51.100.2"},
+		{"10.1.2.3:1234", "", "10.1.2.3"},
+		{"192.168.1.1:1234", "garbage, 198.51.100.3", "198.51.100.3"},
+		{"[::ffff:10.0.0.1]:1234", "198.51.100.4", "198.51.100.4"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = test.remoteAddr
+		if test.forwarded != "" {
+			req.Header.Set("X-Forwarded-For", test.forwarded)
+		}
+		if ip := key(req); ip != test.expected {
+			t.Errorf("Wrong client IP for %s via %q: %s", test.remoteAddr, test.forwarded, ip)
+		}
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Invalid proxy accepted")
+		}
+	}()
+	RateLimitByClientIP("not an address")
+}
+
+// failingStore is a RateLimitStore that is always unavailable
+type failingStore struct{}
+
+func (failingStore) Take(ctx context.Context, key string, limit RateLimit) (RateLimitResult, error) {
+	return RateLimitResult{}, errors.New("unavailable")
+}
+
+func TestRateLimiter_StoreFailure(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").
+		Middleware(NewRateLimiter(RateLimit{Requests: 1, Per: time.Minute}, failingStore{})).
+		Get(dummyHandler("ok"))
+
+	for i := 0; i < 3; i++ {
+		if rec := rateLimitRequest(s, http.MethodGet, "/", "10.0.0.1:1"); rec.Code != http.StatusOK {
+			t.Error("Request rejected when the store failed", rec.Code)
+		}
+	}
+}
+
+func TestMemoryRateLimitStore_Sweep(t *testing.T) {
+	clock := &fakeClock{t: time.Now()}
+	store := NewMemoryRateLimitStore()
+	store.now = clock.now
+
+	limit := RateLimit{Requests: 10, Per: time.Second}
+	store.Take(context.Background(), "a", limit)
+	store.Take(context.Background(), "b", limit)
+
+	clock.t = clock.t.Add(2 * time.Minute)
+	store.Take(context.Background(), "c", limit)
+
+	if len(store.buckets) != 1 {
+		t.Error("Full buckets not removed", len(store.buckets))
+	}
+}
+
+func TestRateLimiter_RouteLimitsByHost(t *testing.T) {
+	limiter, _ := newTestRateLimiter(RateLimit{Requests: 100, Per: time.Minute})
+
+	s := NewServeMux()
+	s.RouteHost("a.com", "/").Middleware(limiter)
+	s.RouteHost("b.com", "/").Middleware(limiter)
+	s.RouteHost("a.com", "/search").RateLimit(RateLimit{Requests: 1, Per: time.Minute}).Get(dummyHandler("a"))
+	s.RouteHost("b.com", "/search").RateLimit(RateLimit{Requests: 1, Per: time.Minute}).Get(dummyHandler("b"))
+
+	for _, host := range []string{"a.com", "b.com"} {
+		rec := rateLimitRequest(s, http.MethodGet, "http://"+host+"/search", "10.0.0.1:1234")
+		if rec.Code != http.StatusOK {
+			t.Error("Host sharing another host's bucket", host, rec.Code)
+		}
+	}
+
+	if rec := rateLimitRequest(s, http.MethodGet, "http://a.com/search", "10.0.0.1:1234"); rec.Code != http.StatusTooManyRequests {
+		t.Error("Host limit not applied", rec.Code)
+	}
+}
@@ -0,0 +1,94 @@
+package powermux
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"runtime/debug"
+)
+
+// PanicLogger logs panics recovered by the Recovery middleware.
+type PanicLogger interface {
+	LogPanic(req *http.Request, err interface{}, stack []byte)
+}
+
+// The PanicLoggerFunc type is an adapter to allow the use of ordinary functions as PanicLoggers.
+type PanicLoggerFunc func(req *http.Request, err interface{}, stack []byte)
+
+// LogPanic calls f(req, err, stack).
+func (f PanicLoggerFunc) LogPanic(req *http.Request, err interface{}, stack []byte) {
+	f(req, err, stack)
+}
+
+// defaultPanicLogger writes panics to the standard logger in the same format as net/http,
+// adding the request ID if there is one
+func defaultPanicLogger(req *http.Request, err interface{}, stack []byte) {
+	if id := RequestID(req); id != "" {
+		log.Printf("powermux: panic serving %s (request %s): %v\n%s", req.URL.Path, id, err, stack)
+		return
+	}
+	log.Printf("powermux: panic serving %s: %v\n%s", req.URL.Path, err, stack)
+}
+
+// Recovery is a middleware that recovers panics from any middleware and handlers after it.
+//
+// Panics are logged along with their stack, and a 500 response is rendered if the response has not
+// already been started. Panics with http.ErrAbortHandler are passed on untouched so the server can
+// abort the response as intended.
+type Recovery struct {
+	// Logger receives every recovered panic. If nil, panics are written to the standard logger.
+	Logger PanicLogger
+
+	// ErrorHandler renders the response for a recovered panic. The panic value is available to it
+	// through RecoveredPanic. If nil, a plain 500 Internal Server Error is sent.
+	ErrorHandler http.Handler
+}
+
+type recoveryCtxKeyType string
+
+var recoveryCtxKey = recoveryCtxKeyType("panic")
+
+// RecoveredPanic returns the value recovered by the Recovery middleware. It is intended for use by
+// Recovery.ErrorHandler and returns nil for any request that did not panic.
+func RecoveredPanic(req *http.Request) interface{} {
+	return req.Context().Value(recoveryCtxKey)
+}
+
+// ServeHTTPMiddleware runs the rest of the chain, recovering from any panic it raises.
+func (m *Recovery) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	w := newResponseWriter(rw)
+
+	defer func() {
+		err := recover()
+		if err == nil {
+			return
+		}
+
+		// the server handles aborts itself and doesn't log them
+		if err == http.ErrAbortHandler {
+			panic(err)
+		}
+
+		stack := debug.Stack()
+		if m.Logger != nil {
+			m.Logger.LogPanic(req, err, stack)
+		} else {
+			defaultPanicLogger(req, err, stack)
+		}
+
+		// too late to change the response
+		if w.Written() {
+			return
+		}
+
+		if m.ErrorHandler == nil {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+			return
+		}
+
+		req = req.WithContext(context.WithValue(req.Context(), recoveryCtxKey, err))
+		m.ErrorHandler.ServeHTTP(w, req)
+	}()
+
+	next(w, req)
+}
@@ -0,0 +1,161 @@
+package powermux
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func panicHandler(v interface{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		panic(v)
+	}
+}
+
+func TestRecovery_Default(t *testing.T) {
+	s := NewServeMux()
+
+	var logged interface{}
+	var stack []byte
+
+	s.Route("/").
+		Middleware(&Recovery{
+			Logger: PanicLoggerFunc(func(req *http.Request, err interface{}, st []byte) {
+				logged = err
+				stack = st
+			}),
+		}).
+		Get(panicHandler("boom"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusInternalServerError {
+		t.Error("Wrong response code. Expected 500 got", rec.Code)
+	}
+
+	if logged != "boom" {
+		t.Error("Panic not logged", logged)
+	}
+
+	if !strings.Contains(string(stack), "panicHandler") {
+		t.Error("Stack doesn't include the panicking function")
+	}
+}
+
+func TestRecovery_ErrorHandler(t *testing.T) {
+	s := NewServeMux()
+
+	var recovered interface{}
+
+	s.Route("/").
+		Middleware(&Recovery{
+			Logger: PanicLoggerFunc(func(*http.Request, interface{}, []byte) {}),
+			ErrorHandler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				recovered = RecoveredPanic(r)
+				w.WriteHeader(http.StatusTeapot)
+			}),
+		}).
+		Get(panicHandler("boom"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Error("Error handler not used. Got", rec.Code)
+	}
+
+	if recovered != "boom" {
+		t.Error("Wrong panic value passed to error handler", recovered)
+	}
+}
+
+func TestRecovery_AlreadyWritten(t *testing.T) {
+	s := NewServeMux()
+
+	s.Route("/").
+		Middleware(&Recovery{
+			Logger: PanicLoggerFunc(func(*http.Request, interface{}, []byte) {}),
+		}).
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "partial")
+			panic("boom")
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	rec := httptest.NewRecorder()
+
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Error("Status changed after the response was started", rec.Code)
+	}
+
+	if rec.Body.String() != "partial" {
+		t.Error("Body changed after the response was started", rec.Body.String())
+	}
+}
+
+func TestRecovery_AbortHandler(t *testing.T) {
+	s := NewServeMux()
+
+	var logged bool
+
+	s.Route("/").
+		Middleware(&Recovery{
+			Logger: PanicLoggerFunc(func(*http.Request, interface{}, []byte) {
+				logged = true
+			}),
+		}).
+		Get(panicHandler(http.ErrAbortHandler))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	defer func() {
+		if err := recover(); err != http.ErrAbortHandler {
+			t.Error("Abort panic not passed on", err)
+		}
+		if logged {
+			t.Error("Abort panic should not be logged")
+		}
+	}()
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestServeMux_PanicReturnsExecution(t *testing.T) {
+	s := NewServeMux()
+
+	var ex *routeExecution
+
+	s.Route("/:id").
+		MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+			ex = getRequestExecution(r)
+			n(w, r)
+		}).
+		Get(panicHandler("boom"))
+
+	req := httptest.NewRequest(http.MethodGet, "/andrew", nil)
+
+	func() {
+		defer func() {
+			recover()
+		}()
+		s.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	if ex == nil {
+		t.Fatal("Middleware not run")
+	}
+
+	// returning to the pool resets the execution
+	if ex.handler != nil || len(ex.params) != 0 {
+		t.Error("Execution was not returned to the pool")
+	}
+}
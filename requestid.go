@@ -0,0 +1,135 @@
+package powermux
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+// DefaultRequestIDHeader is the header carrying request IDs when no other is given
+const DefaultRequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength is the longest incoming request ID accepted by default
+const maxRequestIDLength = 128
+
+type requestIDCtxKeyType string
+
+var requestIDCtxKey = requestIDCtxKeyType("id")
+
+// RequestID returns the ID given to the request by the RequestIDs middleware,
+// or an empty string if it has none.
+func RequestID(req *http.Request) string {
+	return RequestIDFromContext(req.Context())
+}
+
+// RequestIDFromContext returns the request ID stored in a request's context, or an empty string if there is none.
+//
+// Middleware running before RequestIDs, such as AccessLog and Recovery, still see the ID once the rest of the
+// chain has run, as it is also recorded with the request's route.
+func RequestIDFromContext(ctx context.Context) string {
+	if id, ok := ctx.Value(requestIDCtxKey).(string); ok {
+		return id
+	}
+	if ex, ok := ctx.Value(executionKey).(*routeExecution); ok {
+		return ex.requestID
+	}
+	return ""
+}
+
+// InjectRequestID sets the request ID header for an outgoing request from the ID in ctx,
+// so downstream services can correlate their logs with ours.
+func InjectRequestID(ctx context.Context, header http.Header) {
+	if id := RequestIDFromContext(ctx); id != "" {
+		header.Set(DefaultRequestIDHeader, id)
+	}
+}
+
+// RequestIDs is a middleware that gives every request an ID to correlate logs and responses.
+//
+// A valid ID in the incoming request header is kept, otherwise a random UUID is generated. The ID is sent back in
+// the same response header, set on the request so it is passed on by proxy routes, and available to handlers
+// through RequestID. AccessLog entries and panics logged by Recovery include it.
+type RequestIDs struct {
+	// Header is the request and response header carrying the ID. If empty, DefaultRequestIDHeader is used.
+	Header string
+
+	// Generate creates IDs for requests without a valid one. If nil, random UUIDs are used.
+	Generate func() string
+
+	// Validate decides whether an incoming ID is kept. If nil, IDs of up to 128 letters, digits and the
+	// characters "-_.:+/=@" are accepted.
+	Validate func(id string) bool
+}
+
+// ServeHTTPMiddleware assigns the request ID and runs the rest of the chain
+func (m *RequestIDs) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	header := m.Header
+	if header == "" {
+		header = DefaultRequestIDHeader
+	}
+
+	validate := m.Validate
+	if validate == nil {
+		validate = validRequestID
+	}
+
+	id := req.Header.Get(header)
+	if id == "" || !validate(id) {
+		if m.Generate != nil {
+			id = m.Generate()
+		} else {
+			id = newUUID()
+		}
+	}
+
+	if ex := getRequestExecution(req); ex != nil {
+		ex.requestID = id
+	}
+
+	// set before the response starts, so it is sent whatever the handler does
+	rw.Header().Set(header, id)
+
+	req = req.WithContext(context.WithValue(req.Context(), requestIDCtxKey, id))
+	req.Header = req.Header.Clone()
+	req.Header.Set(header, id)
+
+	next(rw, req)
+}
+
+// validRequestID returns whether an incoming ID is short and free of characters that could forge log entries
+func validRequestID(id string) bool {
+	if len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		switch {
+		case c >= 'a' && c <= 'z', c >= 'A' && c <= 'Z', c >= '0' && c <= '9':
+		case c == '-', c == '_', c == '.', c == ':', c == '+', c == '/', c == '=', c == '@':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// newUUID returns a random version 4 UUID
+func newUUID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+
+	var buf [36]byte
+	hex.Encode(buf[0:8], b[0:4])
+	buf[8] = '-'
+	hex.Encode(buf[9:13], b[4:6])
+	buf[13] = '-'
+	hex.Encode(buf[14:18], b[6:8])
+	buf[18] = '-'
+	hex.Encode(buf[19:23], b[8:10])
+	buf[23] = '-'
+	hex.Encode(buf[24:], b[10:])
+	return string(buf[:])
+}
@@ -0,0 +1,164 @@
+package powermux
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+var uuidPattern = regexp.MustCompile(`^[0-9a-f]{8}-[0-9a-f]{4}-4[0-9a-f]{3}-[89ab][0-9a-f]{3}-[0-9a-f]{12}$`)
+
+func TestRequestIDs(t *testing.T) {
+	var seen, forwarded string
+
+	s := NewServeMux()
+	s.Route("/").Middleware(&RequestIDs{})
+	s.Route("/a").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r)
+		forwarded = r.Header.Get("X-Request-ID")
+	})
+
+	tests := []struct {
+		incoming string
+		kept     bool
+	}{
+		{"", false},
+		{"abc-123", true},
+		{"req:42/a+b=@x.y_z", true},
+		{"has space", false},
+		{"forged\nline", false},
+		{strings.Repeat("a", 129), false},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		if test.incoming != "" {
+			req.Header.Set("X-Request-ID", test.incoming)
+		}
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if test.kept && seen != test.incoming {
+			t.Error("Valid incoming ID not kept", test.incoming, seen)
+		}
+		if !test.kept && !uuidPattern.MatchString(seen) {
+			t.Error("ID not generated for", test.incoming, seen)
+		}
+		if rec.Header().Get("X-Request-ID") != seen {
+			t.Error("ID not sent in the response", rec.Header().Get("X-Request-ID"))
+		}
+		if forwarded != seen {
+			t.Error("ID not set on the request", forwarded)
+		}
+		if test.incoming != "" && req.Header.Get("X-Request-ID") != test.incoming {
+			t.Error("Original request headers modified")
+		}
+	}
+}
+
+func TestRequestIDs_Options(t *testing.T) {
+	var seen string
+
+	s := NewServeMux()
+	s.Route("/").Middleware(&RequestIDs{
+		Header:   "X-Correlation-ID",
+		Generate: func() string { return "generated" },
+		Validate: func(id string) bool { return strings.HasPrefix(id, "ok-") },
+	})
+	s.Route("/a").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		seen = RequestID(r)
+	})
+
+	tests := []struct {
+		incoming string
+		id       string
+	}{
+		{"ok-1", "ok-1"},
+		{"bad", "generated"},
+		{"", "generated"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		req.Header.Set("X-Correlation-ID", test.incoming)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if seen != test.id || rec.Header().Get("X-Correlation-ID") != test.id {
+			t.Error("Wrong ID for", test.incoming, seen, rec.Header())
+		}
+	}
+}
+
+func TestRequestIDs_AccessLog(t *testing.T) {
+	buf := &bytes.Buffer{}
+
+	// the access log runs first but still sees the ID
+	s := NewServeMux()
+	s.Route("/").
+		Middleware(&AccessLog{Logger: slog.New(slog.NewJSONHandler(buf, nil))}).
+		Middleware(&RequestIDs{})
+	s.Route("/a").GetFunc(func(w http.ResponseWriter, r *http.Request) {})
+
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	entry := make(map[string]interface{})
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatal("Entry isn't JSON", err, buf.String())
+	}
+	if entry["request_id"] != "abc" {
+		t.Error("Request ID not logged", entry)
+	}
+}
+
+func TestRequestIDs_Recovery(t *testing.T) {
+	var logged string
+
+	s := NewServeMux()
+	s.Route("/").
+		Middleware(&Recovery{
+			Logger: PanicLoggerFunc(func(req *http.Request, err interface{}, stack []byte) {
+				logged = RequestID(req)
+			}),
+		}).
+		Middleware(&RequestIDs{}).
+		Get(panicHandler("boom"))
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Request-ID", "abc")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if logged != "abc" {
+		t.Error("Request ID not available to the panic logger", logged)
+	}
+	if rec.Code != http.StatusInternalServerError || rec.Header().Get("X-Request-ID") != "abc" {
+		t.Error("Request ID not sent with the error", rec.Code, rec.Header())
+	}
+}
+
+func TestInjectRequestID(t *testing.T) {
+	header := http.Header{}
+	InjectRequestID(context.Background(), header)
+	if len(header) != 0 {
+		t.Error("Header set without an ID", header)
+	}
+
+	ctx := context.WithValue(context.Background(), requestIDCtxKey, "abc")
+	InjectRequestID(ctx, header)
+	if header.Get("X-Request-ID") != "abc" {
+		t.Error("ID not injected", header)
+	}
+
+	if RequestID(httptest.NewRequest(http.MethodGet, "/", nil)) != "" {
+		t.Error("ID outside a mux")
+	}
+}
@@ -0,0 +1,449 @@
+package powermux
+
+import (
+	"container/list"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CachedResponse is a response stored by the ResponseCache middleware.
+type CachedResponse struct {
+	Status int
+	Header http.Header
+	Body   []byte
+	// Stored is when the response was generated
+	Stored time.Time
+	// Fresh is when the response becomes stale
+	Fresh time.Time
+	// Expires is when the response can no longer be used, even while revalidating
+	Expires time.Time
+	// Vary lists the request headers named by the response's Vary header. A response that varies is stored under
+	// a key including the values of these headers, and its key without them holds an entry with only Vary and
+	// the times set, which leads lookups to it.
+	Vary []string
+}
+
+// ResponseCacheStore holds the responses of a ResponseCache.
+// Implementations must be safe for concurrent use and must not modify stored responses.
+type ResponseCacheStore interface {
+	// Get returns the response stored for key
+	Get(key string) (*CachedResponse, bool)
+	// Set stores a response for key, replacing any existing one
+	Set(key string, resp *CachedResponse)
+	// DeletePrefix removes the responses of every key beginning with prefix
+	DeletePrefix(prefix string)
+}
+
+// lruEntry is an element of an LRUResponseCacheStore
+type lruEntry struct {
+	key  string
+	resp *CachedResponse
+}
+
+// LRUResponseCacheStore is a ResponseCacheStore holding a bounded number of responses in memory,
+// discarding the least recently used when full.
+type LRUResponseCacheStore struct {
+	lock    sync.Mutex
+	max     int
+	order   *list.List
+	entries map[string]*list.Element
+}
+
+// NewLRUResponseCacheStore creates a store holding up to maxEntries responses
+func NewLRUResponseCacheStore(maxEntries int) *LRUResponseCacheStore {
+	return &LRUResponseCacheStore{
+		max:     maxEntries,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+// Get returns the response for key, marking it as recently used
+func (s *LRUResponseCacheStore) Get(key string) (*CachedResponse, bool) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(e)
+	return e.Value.(*lruEntry).resp, true
+}
+
+// Set stores the response for key, evicting the least recently used response if the store is full
+func (s *LRUResponseCacheStore) Set(key string, resp *CachedResponse) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		e.Value.(*lruEntry).resp = resp
+		s.order.MoveToFront(e)
+		return
+	}
+
+	s.entries[key] = s.order.PushFront(&lruEntry{
+		key:  key,
+		resp: resp,
+	})
+
+	for s.max > 0 && s.order.Len() > s.max {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.entries, oldest.Value.(*lruEntry).key)
+	}
+}
+
+// DeletePrefix removes every response whose key begins with prefix
+func (s *LRUResponseCacheStore) DeletePrefix(prefix string) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	for key, e := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.order.Remove(e)
+			delete(s.entries, key)
+		}
+	}
+}
+
+// Len returns the number of responses stored
+func (s *LRUResponseCacheStore) Len() int {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	return s.order.Len()
+}
+
+// cacheFlight is a response being generated for a key
+type cacheFlight struct {
+	done chan struct{}
+	resp *CachedResponse
+	// the key the response was stored under, which includes the request headers it varies by
+	stored string
+}
+
+// ResponseCache is a middleware that stores successful GET responses and serves them to later requests.
+//
+// Responses are keyed by route pattern, path parameters, host, path, the query parameters listed in Query, the
+// request headers listed in Vary and those named by the response's own Vary header, so requests differing only
+// in other query parameters or headers share a response.
+// Concurrent requests that miss the cache wait for a single response to be generated. Once a response is stale,
+// the next request generates a fresh one while others continue to be served the stale response, for up to
+// StaleWhileRevalidate.
+//
+// Only 200 responses without Set-Cookie, Cache-Control no-store or private directives, or Vary: * are stored. Requests with
+// an Authorization header bypass the cache unless it is listed in Vary, as do authenticated requests and those
+// for routes protected by a policy other than Anyone, whose responses depend on who is asking.
+type ResponseCache struct {
+	store   ResponseCacheStore
+	lock    sync.Mutex
+	flights map[string]*cacheFlight
+	now     func() time.Time
+
+	// TTL is how long responses are fresh
+	TTL time.Duration
+
+	// StaleWhileRevalidate is how long stale responses may be served while a fresh one is generated
+	StaleWhileRevalidate time.Duration
+
+	// Query lists the query parameters that distinguish responses. Others are ignored.
+	Query []string
+
+	// Vary lists the request headers that distinguish responses.
+	Vary []string
+}
+
+// NewResponseCache creates a ResponseCache keeping responses fresh for ttl.
+// If store is nil, up to 1000 responses are kept in memory.
+func NewResponseCache(store ResponseCacheStore, ttl time.Duration) *ResponseCache {
+	if store == nil {
+		store = NewLRUResponseCacheStore(1000)
+	}
+	return &ResponseCache{
+		store:   store,
+		flights: make(map[string]*cacheFlight),
+		now:     time.Now,
+		TTL:     ttl,
+	}
+}
+
+// Invalidate removes the stored responses of a route pattern, as returned by RequestPath, for the given
+// path parameters. If params is nil, the responses for every value of the parameters are removed.
+func (c *ResponseCache) Invalidate(pattern string, params map[string]string) {
+	prefix := pattern + "\x00"
+	if params != nil {
+		prefix += encodeParams(params) + "\x00"
+	}
+	c.store.DeletePrefix(prefix)
+}
+
+// ServeHTTPMiddleware serves the request from the cache if possible, storing the response otherwise
+func (c *ResponseCache) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	if !c.cacheable(req) {
+		next(rw, req)
+		return
+	}
+
+	key := c.key(req)
+	now := c.now()
+
+	if resp, ok := c.lookup(key, req); ok && now.Before(resp.Expires) {
+		if now.Before(resp.Fresh) {
+			serveCached(rw, req, resp, now)
+			return
+		}
+
+		// stale, so revalidate unless another request already is
+		if _, leader := c.join(key); !leader {
+			serveCached(rw, req, resp, now)
+			return
+		}
+		c.generate(rw, req, next, key)
+		return
+	}
+
+	// a miss, so wait for any request already generating the response
+	if flight, leader := c.join(key); !leader {
+		<-flight.done
+		// the response may vary by headers this request doesn't share
+		if flight.resp != nil && variantKey(key, req, flight.resp.Vary) == flight.stored {
+			serveCached(rw, req, flight.resp, c.now())
+			return
+		}
+		next(rw, req)
+		return
+	}
+	c.generate(rw, req, next, key)
+}
+
+// cacheable returns whether the request may be served from the cache
+func (c *ResponseCache) cacheable(req *http.Request) bool {
+	if req.Method != http.MethodGet && req.Method != http.MethodHead {
+		return false
+	}
+	// switching protocols and event streams hold on to the connection
+	if req.Header.Get("Upgrade") != "" {
+		return false
+	}
+	if ex := getRequestExecution(req); ex != nil {
+		if ex.streaming {
+			return false
+		}
+		// policies are checked after the middleware, so a stored response could reach a request failing them
+		if _, open := ex.policy.(anyonePolicy); ex.policy != nil && !open {
+			return false
+		}
+	}
+	// responses to authenticated requests depend on who made them
+	if RequestIdentity(req) != nil {
+		return false
+	}
+	if req.Header.Get("Authorization") != "" {
+		for _, h := range c.Vary {
+			if http.CanonicalHeaderKey(h) == "Authorization" {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// key returns the cache key of the request. It starts with the pattern and path parameters for Invalidate,
+// followed by the host and path, which wildcards, mounts and host routes don't capture in the pattern.
+func (c *ResponseCache) key(req *http.Request) string {
+	var b strings.Builder
+	b.WriteString(RequestPath(req))
+	b.WriteByte(0)
+	b.WriteString(encodeParams(PathParams(req)))
+	b.WriteByte(0)
+	b.WriteString(strings.ToLower(req.Host))
+	b.WriteByte(0)
+	b.WriteString(req.URL.EscapedPath())
+	b.WriteByte(0)
+
+	query := req.URL.Query()
+	for _, name := range c.Query {
+		for _, value := range query[name] {
+			b.WriteString(url.QueryEscape(name) + "=" + url.QueryEscape(value) + "&")
+		}
+	}
+	b.WriteByte(0)
+
+	for _, name := range c.Vary {
+		for _, value := range req.Header.Values(name) {
+			b.WriteString(url.QueryEscape(name) + "=" + url.QueryEscape(value) + "&")
+		}
+	}
+
+	return b.String()
+}
+
+// lookup returns the response stored for the request, following the entry of a response that varies to the
+// one matching the request's headers
+func (c *ResponseCache) lookup(key string, req *http.Request) (*CachedResponse, bool) {
+	resp, ok := c.store.Get(key)
+	if !ok || len(resp.Vary) == 0 {
+		return resp, ok
+	}
+	return c.store.Get(variantKey(key, req, resp.Vary))
+}
+
+// variantKey returns the key of the response stored for key that varies by the given request headers
+func variantKey(key string, req *http.Request, vary []string) string {
+	if len(vary) == 0 {
+		return key
+	}
+
+	var b strings.Builder
+	b.WriteString(key)
+	b.WriteByte(0)
+	for _, name := range vary {
+		for _, value := range req.Header.Values(name) {
+			b.WriteString(url.QueryEscape(name) + "=" + url.QueryEscape(value) + "&")
+		}
+		b.WriteByte(0)
+	}
+	return b.String()
+}
+
+// varyHeaders returns the request headers named by a response's Vary header, in a stable order
+func varyHeaders(h http.Header) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, v := range h.Values("Vary") {
+		for _, name := range strings.Split(v, ",") {
+			name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// encodeParams formats path parameters in a stable order
+func encodeParams(params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	for _, name := range names {
+		b.WriteString(url.QueryEscape(name) + "=" + url.QueryEscape(params[name]) + "&")
+	}
+	return b.String()
+}
+
+// join returns the flight generating the response for key, starting one if there is none.
+// It returns true if the caller started the flight and must generate the response.
+func (c *ResponseCache) join(key string) (*cacheFlight, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if flight, ok := c.flights[key]; ok {
+		return flight, false
+	}
+
+	flight := &cacheFlight{
+		done: make(chan struct{}),
+	}
+	c.flights[key] = flight
+	return flight, true
+}
+
+// generate runs the rest of the chain, storing and sending the response
+func (c *ResponseCache) generate(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request), key string) {
+	var resp *CachedResponse
+	var stored string
+
+	// always release the waiting requests, even if the handler panics
+	defer func() {
+		c.lock.Lock()
+		flight := c.flights[key]
+		delete(c.flights, key)
+		c.lock.Unlock()
+
+		flight.resp = resp
+		flight.stored = stored
+		close(flight.done)
+	}()
+
+	buf := newResponseBuffer()
+	next(buf, req)
+
+	if req.Method == http.MethodGet && storable(buf) {
+		now := c.now()
+		resp = &CachedResponse{
+			Status:  buf.Status(),
+			Header:  buf.Header().Clone(),
+			Body:    append([]byte(nil), buf.body.Bytes()...),
+			Stored:  now,
+			Fresh:   now.Add(c.TTL),
+			Expires: now.Add(c.TTL + c.StaleWhileRevalidate),
+			Vary:    varyHeaders(buf.Header()),
+		}
+
+		stored = variantKey(key, req, resp.Vary)
+		if stored != key {
+			c.store.Set(key, &CachedResponse{
+				Stored:  resp.Stored,
+				Fresh:   resp.Fresh,
+				Expires: resp.Expires,
+				Vary:    resp.Vary,
+			})
+		}
+		c.store.Set(stored, resp)
+	}
+
+	buf.writeTo(rw)
+}
+
+// storable returns whether a response may be stored
+func storable(buf *responseBuffer) bool {
+	if buf.Status() != http.StatusOK || buf.Header().Get("Set-Cookie") != "" {
+		return false
+	}
+	for _, directive := range strings.Split(buf.Header().Get("Cache-Control"), ",") {
+		switch strings.ToLower(strings.TrimSpace(directive)) {
+		case "no-store", "private":
+			return false
+		}
+	}
+	// the response depends on more than the request's headers
+	for _, name := range varyHeaders(buf.Header()) {
+		if name == "*" {
+			return false
+		}
+	}
+	return true
+}
+
+// serveCached sends a stored response, answering conditional requests for it
+func serveCached(rw http.ResponseWriter, req *http.Request, resp *CachedResponse, now time.Time) {
+	h := rw.Header()
+	for k, v := range resp.Header {
+		h[k] = append([]string(nil), v...)
+	}
+	h.Set("Age", strconv.FormatInt(int64(now.Sub(resp.Stored)/time.Second), 10))
+
+	if notModified(req, h) {
+		h.Del("Content-Type")
+		h.Del("Content-Length")
+		h.Del("Content-Encoding")
+		rw.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	rw.WriteHeader(resp.Status)
+	rw.Write(resp.Body)
+}
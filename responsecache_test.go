@@ -0,0 +1,508 @@
+package powermux
+
+import (
+	"compress/flate"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// countingHandler responds with the number of times it has been called
+type countingHandler struct {
+	calls int32
+}
+
+func (h *countingHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	n := atomic.AddInt32(&h.calls, 1)
+	rw.Header().Set("ETag", `"`+strconv.Itoa(int(n))+`"`)
+	fmt.Fprint(rw, n)
+}
+
+func newTestResponseCache(ttl time.Duration) (*ResponseCache, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	cache := NewResponseCache(nil, ttl)
+	cache.now = clock.now
+	return cache, clock
+}
+
+func TestResponseCache(t *testing.T) {
+	cache, clock := newTestResponseCache(time.Minute)
+	cache.Query = []string{"page"}
+
+	h := &countingHandler{}
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/users/:id").Get(h).Post(h)
+
+	if rec := cacheRequest(s, http.MethodGet, "/users/1?page=2&utm=a", nil); rec.Body.String() != "1" {
+		t.Error("Wrong first response", rec.Body.String())
+	}
+
+	clock.t = clock.t.Add(10 * time.Second)
+	rec := cacheRequest(s, http.MethodGet, "/users/1?utm=b&page=2", nil)
+	if rec.Body.String() != "1" {
+		t.Error("Response not served from the cache", rec.Body.String())
+	}
+	if rec.Header().Get("Age") != "10" {
+		t.Error("Wrong Age", rec.Header().Get("Age"))
+	}
+
+	// selected query params and path params distinguish responses
+	if rec := cacheRequest(s, http.MethodGet, "/users/1?page=3", nil); rec.Body.String() != "2" {
+		t.Error("Different query served from the cache", rec.Body.String())
+	}
+	if rec := cacheRequest(s, http.MethodGet, "/users/2?page=2", nil); rec.Body.String() != "3" {
+		t.Error("Different params served from the cache", rec.Body.String())
+	}
+
+	// HEAD requests are served from GET responses
+	if rec := cacheRequest(s, http.MethodHead, "/users/1?page=2", nil); rec.Header().Get("ETag") != `"1"` {
+		t.Error("HEAD not served from the cache", rec.Header())
+	}
+
+	// other methods bypass the cache
+	if rec := cacheRequest(s, http.MethodPost, "/users/1?page=2", nil); rec.Body.String() != "4" {
+		t.Error("POST served from the cache", rec.Body.String())
+	}
+
+	// expired responses are generated again
+	clock.t = clock.t.Add(time.Minute)
+	if rec := cacheRequest(s, http.MethodGet, "/users/1?page=2", nil); rec.Body.String() != "5" {
+		t.Error("Expired response served", rec.Body.String())
+	}
+}
+
+func TestResponseCacheConditional(t *testing.T) {
+	cache, _ := newTestResponseCache(time.Minute)
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/a").Get(&countingHandler{})
+
+	cacheRequest(s, http.MethodGet, "/a", nil)
+
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("If-None-Match", `"1"`)
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusNotModified || rec.Body.Len() != 0 {
+		t.Error("Cached response not revalidated", rec.Code, rec.Body.String())
+	}
+}
+
+func TestResponseCacheVary(t *testing.T) {
+	cache, _ := newTestResponseCache(time.Minute)
+	cache.Vary = []string{"Accept-Language"}
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/a").Get(&countingHandler{})
+
+	tests := []struct {
+		lang string
+		body string
+	}{
+		{"en", "1"},
+		{"fr", "2"},
+		{"en", "1"},
+	}
+
+	for _, test := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/a", nil)
+		req.Header.Set("Accept-Language", test.lang)
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, req)
+
+		if rec.Body.String() != test.body {
+			t.Error("Wrong response for", test.lang, rec.Body.String())
+		}
+	}
+
+	// authorized requests bypass the cache unless it varies on Authorization
+	req := httptest.NewRequest(http.MethodGet, "/a", nil)
+	req.Header.Set("Accept-Language", "en")
+	req.Header.Set("Authorization", "Bearer x")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	if rec.Body.String() != "3" {
+		t.Error("Authorized request served from the cache", rec.Body.String())
+	}
+}
+
+func TestResponseCacheUncacheable(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{"error", func(rw http.ResponseWriter, req *http.Request) {
+			rw.WriteHeader(http.StatusInternalServerError)
+		}},
+		{"no-store", func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Cache-Control", "no-store")
+		}},
+		{"private", func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Cache-Control", "max-age=60, private")
+		}},
+		{"cookie", func(rw http.ResponseWriter, req *http.Request) {
+			rw.Header().Set("Set-Cookie", "a=b")
+		}},
+	}
+
+	for _, test := range tests {
+		cache, _ := newTestResponseCache(time.Minute)
+		calls := 0
+
+		s := NewServeMux()
+		s.Route("/").Middleware(cache)
+		s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+			calls++
+			test.handler(rw, req)
+		})
+
+		cacheRequest(s, http.MethodGet, "/a", nil)
+		cacheRequest(s, http.MethodGet, "/a", nil)
+
+		if calls != 2 {
+			t.Error("Response stored for", test.name)
+		}
+	}
+}
+
+func TestResponseCacheStale(t *testing.T) {
+	cache, clock := newTestResponseCache(time.Minute)
+	cache.StaleWhileRevalidate = time.Minute
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	var calls int32
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		n := atomic.AddInt32(&calls, 1)
+		if n > 1 {
+			started <- struct{}{}
+			<-release
+		}
+		fmt.Fprint(rw, n)
+	})
+
+	cacheRequest(s, http.MethodGet, "/a", nil)
+	clock.t = clock.t.Add(90 * time.Second)
+
+	// the first request after the response is stale revalidates it
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if rec := cacheRequest(s, http.MethodGet, "/a", nil); rec.Body.String() != "2" {
+			t.Error("Revalidating request got", rec.Body.String())
+		}
+	}()
+	<-started
+
+	// meanwhile others get the stale response
+	if rec := cacheRequest(s, http.MethodGet, "/a", nil); rec.Body.String() != "1" {
+		t.Error("Stale response not served while revalidating", rec.Body.String())
+	}
+
+	close(release)
+	wg.Wait()
+
+	if rec := cacheRequest(s, http.MethodGet, "/a", nil); rec.Body.String() != "2" {
+		t.Error("Revalidated response not stored", rec.Body.String())
+	}
+
+	// past the stale window responses are generated again
+	clock.t = clock.t.Add(3 * time.Minute)
+	if rec := cacheRequest(s, http.MethodGet, "/a", nil); rec.Body.String() != "3" {
+		t.Error("Expired stale response served", rec.Body.String())
+	}
+}
+
+func TestResponseCacheCoalescing(t *testing.T) {
+	cache := NewResponseCache(nil, time.Minute)
+
+	release := make(chan struct{})
+	var calls int32
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		fmt.Fprint(rw, "done")
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if rec := cacheRequest(s, http.MethodGet, "/a", nil); rec.Body.String() != "done" {
+				t.Error("Wrong coalesced response", rec.Body.String())
+			}
+		}()
+	}
+
+	// wait for the first request to reach the handler
+	for atomic.LoadInt32(&calls) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if calls != 1 {
+		t.Error("Concurrent misses not coalesced", calls)
+	}
+}
+
+func TestResponseCacheInvalidate(t *testing.T) {
+	cache, _ := newTestResponseCache(time.Minute)
+	cache.Query = []string{"page"}
+
+	h := &countingHandler{}
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/users/:id").Get(h)
+	s.Route("/users/:id/posts/:post").Get(h)
+
+	cacheRequest(s, http.MethodGet, "/users/1?page=1", nil)  // 1
+	cacheRequest(s, http.MethodGet, "/users/1?page=2", nil)  // 2
+	cacheRequest(s, http.MethodGet, "/users/2", nil)         // 3
+	cacheRequest(s, http.MethodGet, "/users/1/posts/1", nil) // 4
+	cache.Invalidate("/users/:id", map[string]string{"id": "1"})
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/users/1?page=1", "5"},
+		{"/users/1?page=2", "6"},
+		{"/users/2", "3"},
+		{"/users/1/posts/1", "4"},
+	}
+
+	for _, test := range tests {
+		if rec := cacheRequest(s, http.MethodGet, test.path, nil); rec.Body.String() != test.body {
+			t.Error("Wrong response after invalidating", test.path, rec.Body.String())
+		}
+	}
+
+	cache.Invalidate("/users/:id", nil)
+	if rec := cacheRequest(s, http.MethodGet, "/users/2", nil); rec.Body.String() != "7" {
+		t.Error("Route not invalidated", rec.Body.String())
+	}
+}
+
+func TestLRUResponseCacheStore(t *testing.T) {
+	store := NewLRUResponseCacheStore(2)
+
+	store.Set("a", &CachedResponse{Status: 1})
+	store.Set("b", &CachedResponse{Status: 2})
+	store.Get("a")
+	store.Set("c", &CachedResponse{Status: 3})
+
+	if _, ok := store.Get("b"); ok {
+		t.Error("Least recently used response not evicted")
+	}
+	if resp, ok := store.Get("a"); !ok || resp.Status != 1 {
+		t.Error("Recently used response evicted")
+	}
+	if store.Len() != 2 {
+		t.Error("Wrong length", store.Len())
+	}
+
+	store.Set("a", &CachedResponse{Status: 4})
+	if resp, _ := store.Get("a"); resp.Status != 4 {
+		t.Error("Response not replaced")
+	}
+
+	store.DeletePrefix("a")
+	if _, ok := store.Get("a"); ok || store.Len() != 1 {
+		t.Error("Prefix not deleted")
+	}
+}
+
+func TestResponseCacheKey(t *testing.T) {
+	cache, _ := newTestResponseCache(time.Minute)
+
+	h := &countingHandler{}
+	users := NewServeMux()
+	users.Route("/:id").Get(h)
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/files/*").Get(h)
+	s.Route("/static").Static(staticFS, nil)
+	s.Route("/users").MountMux(users)
+	for _, host := range []string{"a.example.com", "b.example.com"} {
+		s.RouteHost(host, "/").Middleware(cache)
+		s.RouteHost(host, "/home").Get(h)
+	}
+
+	// requests matching the same pattern with the same params must not share a response
+	tests := []struct {
+		url  string
+		body string
+	}{
+		{"/files/a", "1"},
+		{"/files/b", "2"},
+		{"/static/app.js", "console.log('hi')"},
+		{"/static/images/logo.svg", "<svg/>"},
+		{"/users/1", "3"},
+		{"/users/2", "4"},
+		{"http://a.example.com/home", "5"},
+		{"http://b.example.com/home", "6"},
+	}
+
+	for i := 0; i < 2; i++ {
+		for _, test := range tests {
+			if rec := cacheRequest(s, http.MethodGet, test.url, nil); rec.Body.String() != test.body {
+				t.Error("Wrong response", test.url, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestResponseCachePolicy(t *testing.T) {
+	auth := &Authentication{
+		Authenticators: []Authenticator{&APIKeyAuth{
+			Validate: func(req *http.Request, key string) (*Identity, error) {
+				return &Identity{Subject: key}, nil
+			},
+		}},
+	}
+	header := http.Header{"X-Api-Key": {"k1"}}
+
+	// the cache runs before the policy is checked
+	cache, _ := newTestResponseCache(time.Minute)
+	s := NewServeMux()
+	s.Route("/").Middleware(cache).Middleware(auth)
+	s.Route("/private").Require().Get(&countingHandler{})
+	s.Route("/private/open").RequirePolicy(Anyone).Get(&countingHandler{})
+
+	if rec := cacheRequest(s, http.MethodGet, "/private", header); rec.Code != http.StatusOK {
+		t.Error("Authenticated request rejected", rec.Code)
+	}
+	if rec := cacheRequest(s, http.MethodGet, "/private", nil); rec.Code != http.StatusUnauthorized {
+		t.Error("Protected response served from the cache", rec.Code, rec.Body.String())
+	}
+
+	// routes opened to anyone are cached as usual
+	cacheRequest(s, http.MethodGet, "/private/open", nil)
+	if rec := cacheRequest(s, http.MethodGet, "/private/open", nil); rec.Body.String() != "1" {
+		t.Error("Open route not served from the cache", rec.Body.String())
+	}
+
+	// responses to authenticated requests aren't stored, even without a policy
+	cache, _ = newTestResponseCache(time.Minute)
+	h := &countingHandler{}
+	s = NewServeMux()
+	s.Route("/").Middleware(auth).Middleware(cache)
+	s.Route("/public").Get(h)
+
+	cacheRequest(s, http.MethodGet, "/public", header)
+	if rec := cacheRequest(s, http.MethodGet, "/public", nil); rec.Body.String() != "2" {
+		t.Error("Authenticated response served from the cache", rec.Body.String())
+	}
+}
+
+func TestResponseCacheResponseVary(t *testing.T) {
+	cache, _ := newTestResponseCache(time.Minute)
+
+	calls := 0
+	counted := func(h http.HandlerFunc) http.HandlerFunc {
+		return func(rw http.ResponseWriter, req *http.Request) {
+			calls++
+			h(rw, req)
+		}
+	}
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache).Middleware(NewCompression(flate.DefaultCompression))
+	s.Route("/text").Get(counted(textHandler("text/plain", compressBody)))
+	s.Route("/report").
+		Get(counted(textHandler("application/json", "{}"))).Produces("application/json").
+		Get(counted(textHandler("text/csv", "a,b"))).Produces("text/csv")
+	s.Route("/any").GetFunc(counted(func(rw http.ResponseWriter, req *http.Request) {
+		rw.Header().Set("Vary", "*")
+	}))
+
+	tests := []struct {
+		path     string
+		header   http.Header
+		encoding string
+		body     string
+	}{
+		{"/text", http.Header{"Accept-Encoding": {"gzip"}}, "gzip", compressBody},
+		{"/text", nil, "", compressBody},
+		{"/report", http.Header{"Accept": {"application/json"}}, "", "{}"},
+		{"/report", http.Header{"Accept": {"text/csv"}}, "", "a,b"},
+	}
+
+	// the second round is served from the cache
+	for i := 0; i < 2; i++ {
+		for _, test := range tests {
+			rec := cacheRequest(s, http.MethodGet, test.path, test.header)
+			encoding := rec.Header().Get("Content-Encoding")
+			if encoding != test.encoding || decompress(t, encoding, rec.Body.Bytes()) != test.body {
+				t.Error("Wrong response", test.path, test.header, encoding, rec.Body.String())
+			}
+		}
+	}
+	if calls != len(tests) {
+		t.Error("Responses not served from the cache", calls)
+	}
+
+	calls = 0
+	cacheRequest(s, http.MethodGet, "/any", nil)
+	cacheRequest(s, http.MethodGet, "/any", nil)
+	if calls != 2 {
+		t.Error("Response varying by anything stored")
+	}
+}
+
+func TestResponseCacheCoalescingVary(t *testing.T) {
+	cache, _ := newTestResponseCache(time.Minute)
+	release := make(chan struct{})
+	var calls int32
+
+	s := NewServeMux()
+	s.Route("/").Middleware(cache)
+	s.Route("/a").GetFunc(func(rw http.ResponseWriter, req *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		rw.Header().Set("Vary", "Accept-Language")
+		fmt.Fprint(rw, req.Header.Get("Accept-Language"))
+	})
+
+	var wg sync.WaitGroup
+	for _, lang := range []string{"en", "fr"} {
+		lang := lang
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			rec := cacheRequest(s, http.MethodGet, "/a", http.Header{"Accept-Language": {lang}})
+			if rec.Body.String() != lang {
+				t.Error("Response for other headers served", lang, rec.Body.String())
+			}
+		}()
+
+		// make sure the second request waits on the first
+		for atomic.LoadInt32(&calls) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+}
@@ -0,0 +1,156 @@
+package powermux
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net"
+	"net/http"
+)
+
+// responseWriter wraps an http.ResponseWriter to record the status and size of the response.
+//
+// The optional http.Flusher, http.Hijacker and io.ReaderFrom interfaces are always implemented and passed
+// through to the wrapped writer when it supports them, so wrapping doesn't hide them from later handlers.
+type responseWriter struct {
+	http.ResponseWriter
+	status int
+	size   int64
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{
+		ResponseWriter: w,
+	}
+}
+
+// WriteHeader records the status code and passes it on
+func (w *responseWriter) WriteHeader(code int) {
+	// informational responses may be followed by the real one
+	if w.status == 0 && code >= http.StatusOK {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+// Write records the implicit 200 status if no header was written
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += int64(n)
+	return n, err
+}
+
+// Written returns whether the response has been started
+func (w *responseWriter) Written() bool {
+	return w.status != 0
+}
+
+// Status returns the status code sent, defaulting to 200 as the http server would
+func (w *responseWriter) Status() int {
+	if w.status == 0 {
+		return http.StatusOK
+	}
+	return w.status
+}
+
+// Size returns the number of body bytes written
+func (w *responseWriter) Size() int64 {
+	return w.size
+}
+
+// Flush sends any buffered data to the client if the wrapped writer supports it
+func (w *responseWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		if w.status == 0 {
+			w.status = http.StatusOK
+		}
+		f.Flush()
+	}
+}
+
+// Hijack takes over the connection if the wrapped writer supports it
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// ReadFrom copies from src using the wrapped writer's ReadFrom if it has one
+func (w *responseWriter) ReadFrom(src io.Reader) (int64, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	var n int64
+	var err error
+	if rf, ok := w.ResponseWriter.(io.ReaderFrom); ok {
+		n, err = rf.ReadFrom(src)
+	} else {
+		// hide our own ReadFrom from io.Copy to avoid recursing
+		n, err = io.Copy(struct{ io.Writer }{w.ResponseWriter}, src)
+	}
+	w.size += n
+	return n, err
+}
+
+// Unwrap returns the wrapped writer for use by http.ResponseController
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// responseBuffer is an http.ResponseWriter that holds the whole response so it can be inspected or replaced
+// before being sent
+type responseBuffer struct {
+	header http.Header
+	body   bytes.Buffer
+	status int
+}
+
+func newResponseBuffer() *responseBuffer {
+	return &responseBuffer{
+		header: make(http.Header),
+	}
+}
+
+// Header returns the buffered response headers
+func (b *responseBuffer) Header() http.Header {
+	return b.header
+}
+
+// Write buffers the body
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+// WriteHeader records the status code of the response
+func (b *responseBuffer) WriteHeader(code int) {
+	// informational responses can't be relayed once the response is buffered
+	if b.status != 0 || code < http.StatusOK {
+		return
+	}
+	b.status = code
+}
+
+// Status returns the status code of the response, 200 if none was written
+func (b *responseBuffer) Status() int {
+	if b.status == 0 {
+		return http.StatusOK
+	}
+	return b.status
+}
+
+// writeTo sends the buffered response
+func (b *responseBuffer) writeTo(rw http.ResponseWriter) {
+	dst := rw.Header()
+	for k, v := range b.header {
+		dst[k] = v
+	}
+	rw.WriteHeader(b.Status())
+	rw.Write(b.body.Bytes())
+}
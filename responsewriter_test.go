@@ -0,0 +1,93 @@
+package powermux
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestResponseWriter_Status(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newResponseWriter(rec)
+
+	if w.Written() {
+		t.Error("Fresh writer claims to be written")
+	}
+
+	if w.Status() != http.StatusOK {
+		t.Error("Wrong default status", w.Status())
+	}
+
+	w.WriteHeader(http.StatusEarlyHints)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("hi"))
+
+	if w.Status() != http.StatusCreated {
+		t.Error("Wrong status recorded", w.Status())
+	}
+}
+
+func TestResponseWriter_Size(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newResponseWriter(rec)
+
+	if w.Size() != 0 {
+		t.Error("Fresh writer has a size", w.Size())
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte("hi"))
+	w.Write([]byte(" there"))
+
+	if w.Size() != 8 {
+		t.Error("Wrong size recorded", w.Size())
+	}
+
+	if rec.Body.Len() != 8 {
+		t.Error("Wrong number of bytes passed on", rec.Body.Len())
+	}
+
+	// copied bytes count too
+	w.ReadFrom(strings.NewReader("hello"))
+	if w.Size() != 13 {
+		t.Error("Wrong size recorded after ReadFrom", w.Size())
+	}
+}
+
+func TestResponseWriter_Interfaces(t *testing.T) {
+	rec := httptest.NewRecorder()
+	var w http.ResponseWriter = newResponseWriter(rec)
+
+	if _, ok := w.(http.Flusher); !ok {
+		t.Error("Flusher hidden by wrapper")
+	}
+	w.(http.Flusher).Flush()
+	if !rec.Flushed {
+		t.Error("Flush not passed through")
+	}
+
+	// the recorder can't be hijacked
+	if _, _, err := w.(http.Hijacker).Hijack(); err != http.ErrNotSupported {
+		t.Error("Expected hijacking to be unsupported", err)
+	}
+
+	if w.(interface{ Unwrap() http.ResponseWriter }).Unwrap() != rec {
+		t.Error("Unwrap didn't return the wrapped writer")
+	}
+}
+
+func TestResponseWriter_ReadFrom(t *testing.T) {
+	rec := httptest.NewRecorder()
+	w := newResponseWriter(rec)
+
+	n, err := w.ReadFrom(strings.NewReader("hello"))
+	if err != nil || n != 5 {
+		t.Error("Wrong copy result", n, err)
+	}
+
+	if !bytes.Equal(rec.Body.Bytes(), []byte("hello")) {
+		t.Error("Wrong body", rec.Body.String())
+	}
+}
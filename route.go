@@ -68,15 +68,46 @@ type Route struct {
 	wildcardChild *Route
 	// the map of handlers for different methods
 	handlers map[string]http.Handler
+	// handlers for different methods that only apply to some requests, in order of registration
+	variants map[string][]*handlerVariant
+	// the most recent handler registration
+	last *registration
+	// the timeout for this route and those below it, if set
+	timeout *routeTimeout
+	// the request body limit for this route and those below it, if set
+	bodyLimit *routeBodyLimit
+	// the rate limit for this route and those below it, if declared
+	rateLimit *routeRateLimit
+	// the policies protecting each method of this route and those below it
+	policies map[string]Policy
+	// the error handlers for this route and those below it
+	errorHandlers []ErrorHandler
+	// the caching policies of each method of this route and those below it
+	cachePolicies map[string]*CachePolicy
+	// whether responses of this route and those below it are compressed, if set
+	compression *bool
+	// metadata attached to this route
+	meta map[string]interface{}
+	// metadata attached to this route and those below it
+	inheritedMeta map[string]interface{}
+	// the route above this one
+	parent *Route
+	// the host of a host specific root route
+	host string
 }
 
 // newRoute allocates all the structures required for a route node.
 // Default pattern is "" which matches only the top level node.
 func newRoute() *Route {
 	return &Route{
-		handlers:   make(map[string]http.Handler),
-		middleware: make([]Middleware, 0),
-		children:   make([]*Route, 0),
+		handlers:      make(map[string]http.Handler),
+		variants:      make(map[string][]*handlerVariant),
+		policies:      make(map[string]Policy),
+		cachePolicies: make(map[string]*CachePolicy),
+		meta:          make(map[string]interface{}),
+		inheritedMeta: make(map[string]interface{}),
+		middleware:    make([]Middleware, 0),
+		children:      make([]*Route, 0),
 	}
 }
 
@@ -119,15 +150,50 @@ func (r *Route) getExecution(method string, pathParts []string, ex *routeExecuti
 		// save all the middleware
 		ex.middleware = append(ex.middleware, curRoute.middleware...)
 
+		// save all the error handlers
+		ex.errorHandlers = append(ex.errorHandlers, curRoute.errorHandlers...)
+
 		// save not found handler
 		if h, ok := curRoute.handlers[notFound]; ok {
 			ex.notFound = h
 		}
 
+		// save timeout
+		if curRoute.timeout != nil {
+			ex.timeout = curRoute.timeout
+		}
+
+		// save request body limit
+		if curRoute.bodyLimit != nil {
+			ex.bodyLimit = curRoute.bodyLimit
+		}
+
+		// save rate limit
+		if curRoute.rateLimit != nil {
+			ex.rateLimit = curRoute.rateLimit
+		}
+
+		// save policy
+		if p, ok := curRoute.policyFor(method); ok {
+			ex.policy = p
+		}
+
+		// save caching policy
+		if p, ok := curRoute.cachePolicyFor(method); ok {
+			ex.cache = p
+		}
+
+		// save compression setting
+		if curRoute.compression != nil {
+			ex.compression = curRoute.compression
+		}
+
 		// save options handler
 		if method == http.MethodOptions {
 			if h, ok := curRoute.handlers[http.MethodOptions]; ok {
 				ex.handler = h
+				ex.method = http.MethodOptions
+				ex.kind = HandlerOptions
 			}
 		}
 
@@ -143,6 +209,7 @@ func (r *Route) getExecution(method string, pathParts []string, ex *routeExecuti
 		if len(pathParts) == 1 || curRoute.isWildcard {
 
 			// hit the bottom of the tree, see if we have a handler to offer
+			ex.route = curRoute
 			curRoute.getHandler(method, ex)
 
 			if curRoute.fullPath == "" {
@@ -184,26 +251,46 @@ func (r *Route) getExecution(method string, pathParts []string, ex *routeExecuti
 // 1. An exact method match
 // 2. HEAD requests can use GET handlers
 // 3. The ANY handler
-// 4. A generated Options handler if this is an options request and no previous handler is set
-// 5. A generated Method Not Allowed response
+// 4. A generated Not Acceptable or Unsupported Media Type response if handlers declined the request
+// 5. No handler, leading to the not found handler, if conditional handlers for the method didn't match
+// 6. A generated Options handler if this is an options request and no previous handler is set
+// 7. A generated Method Not Allowed response
+//
+// For each method, handlers with conditions that apply to the request take precedence over the
+// unconditional handler.
 func (r *Route) getHandler(method string, ex *routeExecution) {
 	// check specific method match
-	if h, ok := r.handlers[method]; ok {
-		ex.handler = h
+	if r.selectHandler(method, ex) {
 		return
 	}
 
 	// if this is a HEAD we can fall back on GET
 	if method == http.MethodHead {
-		if h, ok := r.handlers[http.MethodGet]; ok {
-			ex.handler = h
+		if r.selectHandler(http.MethodGet, ex) {
 			return
 		}
 	}
 
 	// check the ANY handler
-	if h, ok := r.handlers[methodAny]; ok {
-		ex.handler = h
+	if r.selectHandler(methodAny, ex) {
+		return
+	}
+
+	// handlers existed but declined the request
+	if ex.rejection != nil {
+		ex.handler = ex.rejection
+		ex.kind = HandlerRejected
+		return
+	}
+
+	// the method is handled, but not for requests like this one
+	if _, ok := r.variants[method]; ok {
+		return
+	}
+	if _, ok := r.variants[http.MethodGet]; ok && method == http.MethodHead {
+		return
+	}
+	if _, ok := r.variants[methodAny]; ok {
 		return
 	}
 
@@ -211,11 +298,35 @@ func (r *Route) getHandler(method string, ex *routeExecution) {
 	// this is regenerated each time in case routes are added during runtime
 	// not generated if a previous handler is already set
 	if ex.handler == nil {
-		ex.handler = r.methodNotAllowed()
+		if h := r.methodNotAllowed(); h != nil {
+			ex.handler = h
+			ex.kind = HandlerMethodNotAllowed
+		}
 	}
 	return
 }
 
+// selectHandler sets the handler registered for a method that applies to the request, if there is one
+func (r *Route) selectHandler(method string, ex *routeExecution) bool {
+	if variants, ok := r.variants[method]; ok {
+		if v := negotiate(variants, ex); v != nil {
+			ex.handler = v
+			ex.method = method
+			ex.kind = HandlerRegistered
+			return true
+		}
+	}
+
+	if h, ok := r.handlers[method]; ok {
+		ex.handler = h
+		ex.method = method
+		ex.kind = HandlerRegistered
+		return true
+	}
+
+	return false
+}
+
 // Route walks down the route tree following pattern and returns either a new or previously
 // existing node that represents that specific path.
 func (r *Route) Route(path string) *Route {
@@ -246,7 +357,11 @@ func (r *Route) Route(path string) *Route {
 	}
 
 	// find/create the new path
-	return r.create(pathParts, r.fullPath)
+	route := r.create(pathParts, r.fullPath)
+
+	// modifiers like Require only apply to handlers registered in the same chain
+	route.last = nil
+	return route
 }
 
 // Create descends the tree following path, creating nodes as needed and returns the target node
@@ -274,6 +389,7 @@ func (r *Route) create(path []string, parentPath string) *Route {
 	newRoute := newRoute()
 
 	// set the pattern name
+	newRoute.parent = r
 	newRoute.pattern = path[1]
 	newRoute.fullPath = r.fullPath + "/" + path[1]
 
@@ -307,7 +423,10 @@ func (r *Route) create(path []string, parentPath string) *Route {
 }
 
 // stringRoutes returns the stringRoutes representation of this route and all below it.
-func (r *Route) stringRoutes(routes *[]string) {
+// The chain is the routes above this one.
+func (r *Route) stringRoutes(routes *[]string, chain []*Route) {
+
+	chain = append(chain, r)
 
 	var thisRoute string
 
@@ -318,19 +437,35 @@ func (r *Route) stringRoutes(routes *[]string) {
 		thisRoute = r.fullPath
 	}
 
-	if len(r.handlers) > 0 {
+	if len(r.handlers) > 0 || len(r.variants) > 0 {
 		thisRoute = thisRoute + "\t["
 		methods := make([]string, 0, 8)
 		for method := range r.handlers {
 			methods = append(methods, method)
 		}
+		for method := range r.variants {
+			if _, ok := r.handlers[method]; !ok {
+				methods = append(methods, method)
+			}
+		}
 		thisRoute = thisRoute + strings.Join(methods, ", ") + "]"
+
+		// show what protects each method
+		if policies := policyStrings(chain, methods); len(policies) > 0 {
+			thisRoute = thisRoute + "\t" + strings.Join(policies, ", ")
+		}
+
+		// and what's attached to it
+		if meta := r.metaString(); meta != "" {
+			thisRoute = thisRoute + "\t{" + meta + "}"
+		}
+
 		*routes = append(*routes, thisRoute)
 	}
 
 	// recursion
 	for _, child := range r.getChildren() {
-		child.stringRoutes(routes)
+		child.stringRoutes(routes, chain[:len(chain):len(chain)])
 	}
 }
 
@@ -356,6 +491,20 @@ func (r *Route) getChildren() []*Route {
 	return allRoutes
 }
 
+// handle registers the unconditional handler for a method, keeping track of what it replaced
+// in case conditions are applied to it afterwards
+func (r *Route) handle(method string, handler http.Handler) *Route {
+	previous, hadPrevious := r.handlers[method]
+	r.last = &registration{
+		method:      method,
+		handler:     handler,
+		previous:    previous,
+		hadPrevious: hadPrevious,
+	}
+	r.handlers[method] = handler
+	return r
+}
+
 // Middleware adds a middleware to this Route.
 //
 // Middlewares are executed if the path to the target route crosses this route.
@@ -372,8 +521,7 @@ func (r *Route) MiddlewareFunc(m MiddlewareFunc) *Route {
 // Any registers a catch-all handler for any method sent to this route.
 // This takes lower precedence than a specific method match.
 func (r *Route) Any(handler http.Handler) *Route {
-	r.handlers[methodAny] = handler
-	return r
+	return r.handle(methodAny, handler)
 }
 
 // AnyFunc registers a plain function as a catch-all handler
@@ -385,8 +533,7 @@ func (r *Route) AnyFunc(f http.HandlerFunc) *Route {
 
 // Post adds a handler for POST methods to this route.
 func (r *Route) Post(handler http.Handler) *Route {
-	r.handlers[http.MethodPost] = handler
-	return r
+	return r.handle(http.MethodPost, handler)
 }
 
 // PostFunc adds a plain function as a handler
@@ -397,8 +544,7 @@ func (r *Route) PostFunc(f http.HandlerFunc) *Route {
 
 // Put adds a handler for PUT methods to this route.
 func (r *Route) Put(handler http.Handler) *Route {
-	r.handlers[http.MethodPut] = handler
-	return r
+	return r.handle(http.MethodPut, handler)
 }
 
 // PutFunc adds a plain function as a handler
@@ -409,8 +555,7 @@ func (r *Route) PutFunc(f http.HandlerFunc) *Route {
 
 // Patch adds a handler for PATCH methods to this route.
 func (r *Route) Patch(handler http.Handler) *Route {
-	r.handlers[http.MethodPatch] = handler
-	return r
+	return r.handle(http.MethodPatch, handler)
 }
 
 // PatchFunc adds a plain function as a handler
@@ -423,8 +568,7 @@ func (r *Route) PatchFunc(f http.HandlerFunc) *Route {
 // GET handlers will also be called for HEAD requests
 // if no specific HEAD handler is registered.
 func (r *Route) Get(handler http.Handler) *Route {
-	r.handlers[http.MethodGet] = handler
-	return r
+	return r.handle(http.MethodGet, handler)
 }
 
 // GetFunc adds a plain function as a handler
@@ -437,8 +581,7 @@ func (r *Route) GetFunc(f http.HandlerFunc) *Route {
 
 // Delete adds a handler for DELETE methods to this route.
 func (r *Route) Delete(handler http.Handler) *Route {
-	r.handlers[http.MethodDelete] = handler
-	return r
+	return r.handle(http.MethodDelete, handler)
 }
 
 // DeleteFunc adds a plain function as a handler
@@ -449,8 +592,7 @@ func (r *Route) DeleteFunc(f http.HandlerFunc) *Route {
 
 // Head adds a handler for HEAD methods to this route.
 func (r *Route) Head(handler http.Handler) *Route {
-	r.handlers[http.MethodHead] = handler
-	return r
+	return r.handle(http.MethodHead, handler)
 }
 
 // HeadFunc adds a plain function as a handler
@@ -461,8 +603,7 @@ func (r *Route) HeadFunc(f http.HandlerFunc) *Route {
 
 // Connect adds a handler for CONNECT methods to this route.
 func (r *Route) Connect(handler http.Handler) *Route {
-	r.handlers[http.MethodConnect] = handler
-	return r
+	return r.handle(http.MethodConnect, handler)
 }
 
 // ConnectFunc adds a plain function as a handler
@@ -475,8 +616,7 @@ func (r *Route) ConnectFunc(f http.HandlerFunc) *Route {
 // This handler will also be called for any routes further down the path
 // from this point if no other OPTIONS handlers are registered below.
 func (r *Route) Options(handler http.Handler) *Route {
-	r.handlers[http.MethodOptions] = handler
-	return r
+	return r.handle(http.MethodOptions, handler)
 }
 
 // OptionsFunc adds a plain function as a handler
@@ -492,6 +632,7 @@ func (r *Route) OptionsFunc(f http.HandlerFunc) *Route {
 // from this point if no other not found handlers are registered below.
 func (r *Route) NotFound(handler http.Handler) *Route {
 	r.handlers[notFound] = handler
+	r.last = nil
 	return r
 }
 
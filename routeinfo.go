@@ -0,0 +1,67 @@
+package powermux
+
+import (
+	"net/http"
+)
+
+// HandlerKind describes where the handler chosen for a request came from.
+type HandlerKind int
+
+const (
+	// HandlerRegistered is a handler registered on the matched route for the request's method, HEAD requests
+	// served by GET handlers, or an ANY handler.
+	HandlerRegistered HandlerKind = iota
+	// HandlerNotFound is the not found handler, used when no route or handler matched.
+	HandlerNotFound
+	// HandlerMethodNotAllowed is the generated Method Not Allowed handler.
+	HandlerMethodNotAllowed
+	// HandlerRedirect is the generated redirect removing a trailing slash.
+	HandlerRedirect
+	// HandlerOptions is an OPTIONS handler inherited from a route above the matched one.
+	HandlerOptions
+	// HandlerRejected is a generated Not Acceptable or Unsupported Media Type response.
+	HandlerRejected
+)
+
+// RouteMatch describes the route and handler chosen for a request.
+type RouteMatch struct {
+	// Route is the matched route, or nil if the request didn't reach one
+	Route *Route
+	// Pattern is the pattern of the matched route, as returned by RequestPath
+	Pattern string
+	// Method is the method the chosen handler was registered for. It is "GET" for HEAD requests served by
+	// a GET handler, and "ANY" for catch-all handlers.
+	Method string
+	// Kind is where the handler came from
+	Kind HandlerKind
+}
+
+// Synthetic returns whether the handler was generated or inherited rather than registered for the request.
+func (m RouteMatch) Synthetic() bool {
+	return m.Kind != HandlerRegistered
+}
+
+// Meta returns the metadata value for key of the matched route, as returned by Route.GetMeta,
+// or nil if there is none.
+func (m RouteMatch) Meta(key string) interface{} {
+	if m.Route == nil {
+		return nil
+	}
+	return m.Route.GetMeta(key)
+}
+
+// RouteInfo returns the route and handler the request was routed to. It is intended for middleware that needs to
+// know what will handle the request before it runs. Requests that were not routed by a ServeMux return a
+// RouteMatch without a Route.
+func RouteInfo(req *http.Request) RouteMatch {
+	ex := getRequestExecution(req)
+	if ex == nil {
+		return RouteMatch{}
+	}
+	return RouteMatch{
+		Route:   ex.route,
+		Pattern: ex.pattern,
+		Method:  ex.method,
+		Kind:    ex.kind,
+	}
+}
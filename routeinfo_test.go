@@ -0,0 +1,102 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRouteInfo(t *testing.T) {
+	s := NewServeMux()
+
+	var info RouteMatch
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		info = RouteInfo(r)
+		n(w, r)
+	})
+
+	users := s.Route("/users/:id").Get(dummyHandler("user")).Meta("audit", true)
+	s.Route("/report").Get(dummyHandler("json")).Produces("application/json")
+	s.Route("/any").Any(dummyHandler("any"))
+	s.Route("/api").Options(dummyHandler("cors"))
+	s.Route("/api/items").Get(dummyHandler("items"))
+
+	tests := []struct {
+		method  string
+		path    string
+		route   *Route
+		pattern string
+		handler string
+		kind    HandlerKind
+	}{
+		{http.MethodGet, "/users/1", users, "/users/:id", http.MethodGet, HandlerRegistered},
+		{http.MethodHead, "/users/1", users, "/users/:id", http.MethodGet, HandlerRegistered},
+		{http.MethodPut, "/any", s.Route("/any"), "/any", methodAny, HandlerRegistered},
+		{http.MethodPost, "/users/1", users, "/users/:id", "", HandlerMethodNotAllowed},
+		{http.MethodGet, "/nowhere", nil, "", "", HandlerNotFound},
+		{http.MethodOptions, "/api/items", s.Route("/api/items"), "/api/items", http.MethodOptions, HandlerOptions},
+		{http.MethodOptions, "/api", s.Route("/api"), "/api", http.MethodOptions, HandlerRegistered},
+	}
+
+	for _, test := range tests {
+		info = RouteMatch{}
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(test.method, test.path, nil))
+
+		if info.Route != test.route || info.Pattern != test.pattern || info.Method != test.handler || info.Kind != test.kind {
+			t.Errorf("Wrong route info for %s %s: %+v", test.method, test.path, info)
+		}
+		if info.Synthetic() != (test.kind != HandlerRegistered) {
+			t.Errorf("Wrong synthetic flag for %s %s", test.method, test.path)
+		}
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/report", nil)
+	req.Header.Set("Accept", "text/csv")
+	s.ServeHTTP(httptest.NewRecorder(), req)
+	if info.Kind != HandlerRejected {
+		t.Error("Rejection not reported", info)
+	}
+}
+
+func TestRouteInfo_Meta(t *testing.T) {
+	s := NewServeMux()
+
+	var audited interface{}
+	s.Route("/").MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+		audited = RouteInfo(r).Meta("audit")
+		n(w, r)
+	})
+	s.Route("/users/:id").Meta("audit", "users").Get(dummyHandler("user"))
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if audited != "users" {
+		t.Error("Metadata not available", audited)
+	}
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nowhere", nil))
+	if audited != nil {
+		t.Error("Metadata available without a route", audited)
+	}
+
+	if info := RouteInfo(httptest.NewRequest(http.MethodGet, "/", nil)); info.Route != nil || info.Meta("audit") != nil {
+		t.Error("Route info outside a mux", info)
+	}
+}
+
+func TestRouteInfo_WithPathParams(t *testing.T) {
+	s := NewServeMux()
+
+	var info RouteMatch
+	users := s.Route("/users/:id").
+		MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+			n(w, WithPathParams(r, map[string]string{"tab": "posts"}))
+		}).
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {
+			info = RouteInfo(r)
+		})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users/1", nil))
+	if info.Route != users || info.Pattern != "/users/:id" || info.Method != http.MethodGet || info.Kind != HandlerRegistered {
+		t.Error("Route info lost by WithPathParams", info)
+	}
+}
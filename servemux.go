@@ -22,26 +22,35 @@ var (
 	executionKey = ctxKey("ex")
 )
 
+// getRequestExecution returns the execution saved in the request context, or nil if the
+// request was not routed by a ServeMux
 func getRequestExecution(req *http.Request) *routeExecution {
-	ex := req.Context().Value(executionKey).(*routeExecution)
+	ex, _ := req.Context().Value(executionKey).(*routeExecution)
 	return ex
 }
 
 // PathParam gets named path parameters and their values from the request
 //
 // the path '/users/:name' given '/users/andrew' will have `PathParam(r, "name")` => `"andrew"`
-// unset values return an empty stringRoutes
+// unset values return an empty stringRoutes, as do requests that were not routed by a ServeMux
 func PathParam(req *http.Request, name string) (value string) {
 	ex := getRequestExecution(req)
+	if ex == nil {
+		return ""
+	}
 	return ex.params[name]
 }
 
 // PathParams returns the map of all path parameters and their values from the request.
 //
 // Altering the values of this map will not affect future calls to PathParam and PathParams.
+// Requests that were not routed by a ServeMux return an empty map.
 func PathParams(req *http.Request) (params map[string]string) {
 	ex := getRequestExecution(req)
 	params = make(map[string]string)
+	if ex == nil {
+		return
+	}
 	for k, v := range ex.params {
 		params[k] = v
 	}
@@ -50,11 +59,38 @@ func PathParams(req *http.Request) (params map[string]string) {
 
 // RequestPath returns the path definition that the router used to serve this request,
 // without any parameter substitution.
+//
+// Requests that were not routed by a ServeMux return an empty string.
 func RequestPath(req *http.Request) (value string) {
 	ex := getRequestExecution(req)
+	if ex == nil {
+		return ""
+	}
 	return ex.pattern
 }
 
+// WithPathParams returns a shallow copy of req carrying the given path parameters, as though
+// they had been extracted by a ServeMux. Parameters already present on the request are kept
+// unless overwritten by params, as is everything else the mux knows about the request, such as
+// its route and error handlers.
+//
+// This allows handlers to be tested by building requests directly, without a full ServeMux.
+func WithPathParams(req *http.Request, params map[string]string) *http.Request {
+	// carry over everything the mux already set, such as the route and error handlers
+	var ex *routeExecution
+	if prev := getRequestExecution(req); prev != nil {
+		ex = prev.detach()
+	} else {
+		ex = newExecution()
+	}
+
+	for k, v := range params {
+		ex.params[k] = v
+	}
+
+	return req.WithContext(context.WithValue(req.Context(), executionKey, ex))
+}
+
 // NewServeMux creates a new multiplexer, and sets up a default not found handler
 func NewServeMux() *ServeMux {
 	s := &ServeMux{
@@ -79,11 +115,13 @@ func (s *ServeMux) getAll(r *http.Request, ex *routeExecution) {
 	if !s.skipClean && path != "/" && strings.HasSuffix(path, "/") {
 		r.URL.Path = strings.TrimRight(path, "/")
 		ex.handler = http.RedirectHandler(r.URL.RequestURI(), http.StatusPermanentRedirect)
+		ex.kind = HandlerRedirect
 		ex.pattern = r.URL.EscapedPath()
 		return
 	}
 
 	// fill it
+	ex.req = r
 	if route, ok := s.hostRoutes[r.URL.Host]; ok {
 		route.execute(ex, r.Method, path)
 	} else {
@@ -93,6 +131,32 @@ func (s *ServeMux) getAll(r *http.Request, ex *routeExecution) {
 	// fall back on not found handler if necessary
 	if ex.handler == nil {
 		ex.handler = ex.notFound
+		ex.kind = HandlerNotFound
+	}
+
+	// long lived handlers are neither limited nor buffered
+	ex.streaming = streams(ex.handler)
+
+	// limit the handler to the route's timeout
+	if ex.timeout != nil && !ex.streaming {
+		ex.handler = ex.timeout.wrap(ex.handler)
+	}
+
+	// only reads are cached
+	if ex.cache != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) && !ex.streaming {
+		ex.handler = &cacheHandler{
+			handler: ex.handler,
+			policy:  ex.cache,
+		}
+	}
+
+	// check the route's policy before anything else, leaving generated responses such as 404s, 405s and
+	// inherited OPTIONS handlers to answer anyone
+	if ex.policy != nil && ex.kind == HandlerRegistered {
+		ex.handler = &policyHandler{
+			handler: ex.handler,
+			policy:  ex.policy,
+		}
 	}
 
 	return
@@ -103,19 +167,37 @@ func (s *ServeMux) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
 	// Get a route execution from the pool
 	ex := s.executionPool.Get()
 
+	// Always return it, even if a handler panics
+	defer s.executionPool.Put(ex)
+
 	s.getAll(req, ex)
 
+	// Keep the server's writer so upgrade handlers can hijack it, even through a parent mux
+	ex.writer = rw
+	if parent := getRequestExecution(req); parent != nil && parent.writer != nil {
+		ex.writer = parent.writer
+	}
+
 	// Save the execution
 	ctx := context.WithValue(req.Context(), executionKey, ex)
 
+	// Combine with the route we're mounted under, which only applies to this mux
+	if mount := getMountPoint(req); mount != nil {
+		ex.mount(mount)
+		ctx = context.WithValue(ctx, mountCtxKey, nil)
+	}
+
 	// Save context into request
 	req = req.WithContext(ctx)
 
+	// Oversized requests never reach the middleware
+	if ex.bodyLimit != nil && !ex.bodyLimit.apply(rw, req) {
+		return
+	}
+
 	// Run a middleware/handler closure to nest all middleware
 	f := getNextMiddleware(ex.middleware, ex.handler)
 	f(rw, req)
-
-	s.executionPool.Put(ex)
 }
 
 // Handle registers the handler for the given pattern.
@@ -183,6 +265,7 @@ func (s *ServeMux) RouteHost(host, path string) *Route {
 	r, ok := s.hostRoutes[host]
 	if !ok {
 		r = newRoute()
+		r.host = host
 		s.hostRoutes[host] = r
 	}
 	return r.Route(path)
@@ -196,7 +279,7 @@ func (s *ServeMux) NotFound(handler http.Handler) {
 // String returns a list of all routes registered with this server
 func (s *ServeMux) String() string {
 	routes := make([]string, 0, 1)
-	s.baseRoute.stringRoutes(&routes)
+	s.baseRoute.stringRoutes(&routes, nil)
 
 	buf := bytes.Buffer{}
 
@@ -206,7 +289,7 @@ func (s *ServeMux) String() string {
 
 	for host, baseRoute := range s.hostRoutes {
 		routes = routes[0:0]
-		baseRoute.stringRoutes(&routes)
+		baseRoute.stringRoutes(&routes, nil)
 		for _, route := range routes {
 			buf.WriteString(host + route + "\n")
 		}
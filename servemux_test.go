@@ -892,3 +892,64 @@ func TestServeMux_ServeHTTPHost(t *testing.T) {
 		t.Error("Wrong handler executed")
 	}
 }
+
+func TestPathParam_NoMux(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew", nil)
+
+	if PathParam(req, "id") != "" {
+		t.Error("Expected empty path param outside of a mux")
+	}
+
+	if params := PathParams(req); params == nil || len(params) != 0 {
+		t.Error("Expected empty params map outside of a mux", params)
+	}
+
+	if RequestPath(req) != "" {
+		t.Error("Expected empty request path outside of a mux")
+	}
+}
+
+func TestWithPathParams(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew", nil)
+
+	req = WithPathParams(req, map[string]string{"id": "andrew"})
+
+	if PathParam(req, "id") != "andrew" {
+		t.Error("Injected path param not returned", PathParam(req, "id"))
+	}
+
+	// further injection keeps earlier values
+	req = WithPathParams(req, map[string]string{"page": "2"})
+
+	params := PathParams(req)
+	if params["id"] != "andrew" || params["page"] != "2" {
+		t.Error("Wrong params returned", params)
+	}
+}
+
+func TestWithPathParams_InMux(t *testing.T) {
+	s := NewServeMux()
+
+	var id, name, path string
+
+	s.Route("/users/:id").
+		Middleware(MiddlewareFunc(func(w http.ResponseWriter, r *http.Request, n func(http.ResponseWriter, *http.Request)) {
+			n(w, WithPathParams(r, map[string]string{"name": "burian"}))
+		})).
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {
+			id = PathParam(r, "id")
+			name = PathParam(r, "name")
+			path = RequestPath(r)
+		})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew", nil)
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if id != "andrew" || name != "burian" {
+		t.Error("Wrong params returned", id, name)
+	}
+
+	if path != "/users/:id" {
+		t.Error("Request path not preserved", path)
+	}
+}
@@ -0,0 +1,257 @@
+package powermux
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultSSEHeartbeat is how often event streams send a comment to keep idle connections open
+const DefaultSSEHeartbeat = 15 * time.Second
+
+// ErrInvalidEvent is returned when sending an event whose name or ID contains a line break
+var ErrInvalidEvent = errors.New("powermux: event name or ID contains a line break")
+
+// EventStream sends Server-Sent Events to a client. It is safe for concurrent use.
+type EventStream struct {
+	lock      sync.Mutex
+	w         *responseWriter
+	req       *http.Request
+	opened    bool
+	heartbeat time.Duration
+	reset     chan struct{}
+}
+
+// SSE registers a handler for GET requests that streams Server-Sent Events.
+//
+// f is called with the request context, which is cancelled when the client disconnects, and a stream to send
+// events on. The response starts with the first event or heartbeat, or when Open is called, with headers that
+// prevent caching and proxy buffering, and every event is flushed to the client as it is sent. Idle streams send a
+// comment every DefaultSSEHeartbeat to keep the connection open.
+//
+// Errors returned before the response starts are handled like those of HandlerE. Later ones can only be passed to
+// the route's error handlers. Route timeouts and caching policies don't apply to event streams.
+//
+//	mux.Route("/events").SSE(func(ctx context.Context, stream *powermux.EventStream) error {
+//		for {
+//			select {
+//			case <-ctx.Done():
+//				return nil
+//			case n := <-notifications:
+//				if err := stream.Send("notification", n, n.ID); err != nil {
+//					return err
+//				}
+//			}
+//		}
+//	})
+func (r *Route) SSE(f func(ctx context.Context, stream *EventStream) error) *Route {
+	return r.Get(&sseHandler{
+		f: f,
+	})
+}
+
+// sseHandler is the handler registered by Route.SSE
+type sseHandler struct {
+	f func(ctx context.Context, stream *EventStream) error
+}
+
+// ServeHTTP runs the stream, sending heartbeats until it returns
+func (h *sseHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	w := newResponseWriter(rw)
+	stream := &EventStream{
+		w:         w,
+		req:       req,
+		heartbeat: DefaultSSEHeartbeat,
+		reset:     make(chan struct{}, 1),
+	}
+
+	// the heartbeat must stop writing before the handler returns
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		stream.beat(req.Context(), stop)
+	}()
+
+	err := h.f(req.Context(), stream)
+	close(stop)
+	<-done
+
+	// the client leaving isn't an error worth reporting
+	if err != nil && !(req.Context().Err() != nil && errors.Is(err, req.Context().Err())) {
+		handleError(w, req, err, writeProblem)
+	}
+}
+
+// beat sends a comment whenever the stream has been idle for the heartbeat interval
+func (s *EventStream) beat(ctx context.Context, stop chan struct{}) {
+	var timer *time.Timer
+	var tick <-chan time.Time
+	defer func() {
+		if timer != nil {
+			timer.Stop()
+		}
+	}()
+
+	for {
+		// restart the interval, which may have changed
+		if timer != nil {
+			timer.Stop()
+		}
+		timer, tick = nil, nil
+		s.lock.Lock()
+		if s.heartbeat > 0 {
+			timer = time.NewTimer(s.heartbeat)
+			tick = timer.C
+		}
+		s.lock.Unlock()
+
+		select {
+		case <-stop:
+			return
+		case <-ctx.Done():
+			return
+		case <-s.reset:
+		case <-tick:
+			if s.write(":\n\n") != nil {
+				return
+			}
+		}
+	}
+}
+
+// LastEventID returns the ID of the last event the client received before reconnecting,
+// or an empty string if this is its first connection.
+func (s *EventStream) LastEventID() string {
+	return s.req.Header.Get("Last-Event-ID")
+}
+
+// SetHeartbeat changes how often the stream sends a comment while idle. Zero turns heartbeats off.
+func (s *EventStream) SetHeartbeat(d time.Duration) {
+	s.lock.Lock()
+	s.heartbeat = d
+	s.lock.Unlock()
+	s.touch()
+}
+
+// Open starts the response without sending an event, so clients see the stream open immediately
+func (s *EventStream) Open() error {
+	return s.write("")
+}
+
+// Send sends an event to the client and flushes it.
+//
+// Strings and byte slices are sent as they are, anything else is encoded as JSON. Data spanning several lines
+// is split over several data fields, which clients join back together. An empty event name is sent as an
+// unnamed "message" event, and an empty id leaves the client's last event ID unchanged.
+func (s *EventStream) Send(event string, data interface{}, id string) error {
+	if strings.ContainsAny(event, "\r\n") || strings.ContainsAny(id, "\r\n\x00") {
+		return ErrInvalidEvent
+	}
+
+	var text string
+	switch d := data.(type) {
+	case string:
+		text = d
+	case []byte:
+		text = string(d)
+	default:
+		b, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+		text = string(b)
+	}
+
+	var b strings.Builder
+	if event != "" {
+		b.WriteString("event: " + event + "\n")
+	}
+	if id != "" {
+		b.WriteString("id: " + id + "\n")
+	}
+
+	text = strings.ReplaceAll(text, "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	for _, line := range strings.Split(text, "\n") {
+		b.WriteString("data: " + line + "\n")
+	}
+	b.WriteString("\n")
+
+	if err := s.write(b.String()); err != nil {
+		return err
+	}
+	s.touch()
+	return nil
+}
+
+// Retry tells the client how long to wait before reconnecting if the stream is interrupted
+func (s *EventStream) Retry(d time.Duration) error {
+	return s.write("retry: " + strconv.FormatInt(d.Milliseconds(), 10) + "\n\n")
+}
+
+// touch restarts the heartbeat interval
+func (s *EventStream) touch() {
+	select {
+	case s.reset <- struct{}{}:
+	default:
+	}
+}
+
+// write sends raw stream data, starting the response if necessary
+func (s *EventStream) write(data string) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	if err := s.req.Context().Err(); err != nil {
+		return err
+	}
+
+	if !s.opened {
+		s.opened = true
+		h := s.w.Header()
+		h.Set("Content-Type", "text/event-stream")
+		h.Set("Cache-Control", "no-cache")
+		h.Set("X-Accel-Buffering", "no")
+		h.Del("Content-Length")
+		s.w.WriteHeader(http.StatusOK)
+	}
+
+	if data != "" {
+		if _, err := s.w.Write([]byte(data)); err != nil {
+			return err
+		}
+	}
+	return flush(s.w.ResponseWriter, s.req)
+}
+
+// flush sends buffered data to the client. Wrappers added by middleware can pass flushes on silently, or hide
+// the Flusher altogether, so the writer the mux was given is flushed as well.
+func flush(w http.ResponseWriter, req *http.Request) error {
+	err := http.NewResponseController(w).Flush()
+	if err != nil && !errors.Is(err, http.ErrNotSupported) {
+		return err
+	}
+
+	if ex := getRequestExecution(req); ex != nil && ex.writer != nil {
+		return http.NewResponseController(ex.writer).Flush()
+	}
+	return err
+}
+
+// streams returns whether a handler holds on to the connection, so mustn't be buffered or limited
+func streams(h http.Handler) bool {
+	if v, ok := h.(*handlerVariant); ok {
+		if v.upgrade != "" {
+			return true
+		}
+		h = v.handler
+	}
+	_, ok := h.(*sseHandler)
+	return ok
+}
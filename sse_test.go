@@ -0,0 +1,132 @@
+package powermux
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRoute_SSE(t *testing.T) {
+	var sendErr error
+
+	s := NewServeMux()
+	s.Route("/events").SSE(func(ctx context.Context, stream *EventStream) error {
+		stream.Retry(2 * time.Second)
+		stream.Send("", "resumed after "+stream.LastEventID(), "")
+		stream.Send("update", "line one\nline two", "7")
+		stream.Send("json", map[string]int{"n": 1}, "8")
+		sendErr = stream.Send("bad\nname", "x", "")
+		return nil
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/events", nil)
+	req.Header.Set("Last-Event-ID", "6")
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Error("Wrong status", rec.Code)
+	}
+	h := rec.Header()
+	if h.Get("Content-Type") != "text/event-stream" || h.Get("Cache-Control") != "no-cache" || h.Get("X-Accel-Buffering") != "no" {
+		t.Error("Wrong headers", h)
+	}
+	if !rec.Flushed {
+		t.Error("Events not flushed")
+	}
+
+	expected := "retry: 2000\n\n" +
+		"data: resumed after 6\n\n" +
+		"event: update\nid: 7\ndata: line one\ndata: line two\n\n" +
+		"event: json\nid: 8\ndata: {\"n\":1}\n\n"
+	if rec.Body.String() != expected {
+		t.Errorf("Wrong stream\n%q\n%q", rec.Body.String(), expected)
+	}
+
+	if !errors.Is(sendErr, ErrInvalidEvent) {
+		t.Error("Invalid event name sent", sendErr)
+	}
+}
+
+func TestRoute_SSEError(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/events/:id").SSE(func(ctx context.Context, stream *EventStream) error {
+		return ErrorWithStatus(http.StatusNotFound, errors.New("no such feed"))
+	})
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/events/1", nil))
+
+	if rec.Code != http.StatusNotFound || rec.Header().Get("Content-Type") != "application/problem+json" {
+		t.Error("Error before the stream started not reported", rec.Code, rec.Header())
+	}
+}
+
+func TestRoute_SSEStreaming(t *testing.T) {
+	received := make(chan struct{})
+	finished := make(chan struct{})
+
+	s := NewServeMux()
+	s.Route("/").
+		Middleware(&AccessLog{Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}).
+		Middleware(hidingMiddleware{}).
+		Timeout(10*time.Millisecond, nil)
+	s.Route("/events").SSE(func(ctx context.Context, stream *EventStream) error {
+		defer close(finished)
+
+		// outlive the route's timeout
+		time.Sleep(30 * time.Millisecond)
+		if err := stream.Send("first", "1", ""); err != nil {
+			return err
+		}
+
+		// the client can only see the event if it was flushed
+		<-received
+		stream.SetHeartbeat(5 * time.Millisecond)
+
+		<-ctx.Done()
+		return ctx.Err()
+	})
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"/events", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	r := bufio.NewReader(resp.Body)
+	event, _ := r.ReadString('\n')
+	data, _ := r.ReadString('\n')
+	r.ReadString('\n')
+	if event != "event: first\n" || data != "data: 1\n" {
+		t.Error("Wrong event", event, data)
+	}
+	close(received)
+
+	heartbeat, _ := r.ReadString('\n')
+	if strings.TrimSpace(heartbeat) != ":" {
+		t.Error("Wrong heartbeat", heartbeat)
+	}
+
+	// disconnecting ends the stream
+	cancel()
+	select {
+	case <-finished:
+	case <-time.After(time.Second):
+		t.Error("Stream not stopped by disconnect")
+	}
+}
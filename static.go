@@ -0,0 +1,229 @@
+package powermux
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// StaticOptions configures a static file route. The zero value is a usable configuration.
+type StaticOptions struct {
+	// Index is the file served for directories, "index.html" by default.
+	// Directories without an index file are not found, as listings are never generated.
+	Index string
+
+	// SPA serves the root index file for missing paths without a file extension,
+	// so client side routes of single page applications load the application.
+	SPA bool
+
+	// Precompressed serves name.br or name.gz in place of name when they exist
+	// and the client accepts that encoding.
+	Precompressed bool
+}
+
+// precompressedEncodings are the encodings checked, in order of preference
+var precompressedEncodings = []struct {
+	coding string
+	ext    string
+}{
+	{"br", ".br"},
+	{"gzip", ".gz"},
+}
+
+// staticETag is a cached entity tag of a file
+type staticETag struct {
+	modTime time.Time
+	size    int64
+	etag    string
+}
+
+// staticHandler serves files from a file system
+type staticHandler struct {
+	fsys  fs.FS
+	opts  StaticOptions
+	depth int
+	// cache of file name to staticETag
+	etags sync.Map
+}
+
+// Static serves files from fsys for GET and HEAD requests to this route and every path below it.
+//
+// The path below this route selects the file, so Route("/assets").Static(fsys, nil) serves "/assets/app.js"
+// from "app.js" in fsys. Files that don't exist are handled by the NotFound handler inherited by this route.
+//
+// Responses carry a Last-Modified header when the file system provides modification times and an ETag
+// computed from the file contents, and conditional and range requests are answered as by http.ServeContent.
+func (r *Route) Static(fsys fs.FS, opts *StaticOptions) *Route {
+	h := &staticHandler{
+		fsys: fsys,
+	}
+	if opts != nil {
+		h.opts = *opts
+	}
+	if h.opts.Index == "" {
+		h.opts.Index = "index.html"
+	}
+
+	// serve the whole subtree
+	if r.isWildcard {
+		h.depth = strings.Count(r.fullPath, "/") - 1
+		return r.Get(h)
+	}
+
+	h.depth = strings.Count(r.fullPath, "/")
+	r.Route("/*").Get(h)
+	return r.Get(h)
+}
+
+// ServeHTTP serves the requested file or falls back on the not found handler
+func (h *staticHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	name, ok := h.fileName(req)
+
+	if ok && h.serveFile(rw, req, name) {
+		return
+	}
+
+	if h.opts.SPA && path.Ext(name) == "" && h.serveFile(rw, req, h.opts.Index) {
+		return
+	}
+
+	if ex := getRequestExecution(req); ex != nil && ex.notFound != nil {
+		ex.notFound.ServeHTTP(rw, req)
+		return
+	}
+	http.NotFound(rw, req)
+}
+
+// fileName returns the name in the file system the request refers to
+func (h *staticHandler) fileName(req *http.Request) (string, bool) {
+	remainder, err := url.PathUnescape(trimSegments(req.URL.EscapedPath(), h.depth))
+	if err != nil {
+		return "", false
+	}
+
+	name := strings.TrimPrefix(path.Clean(remainder), "/")
+	if name == "" {
+		name = "."
+	}
+
+	return name, fs.ValidPath(name)
+}
+
+// serveFile serves a file, or the index of a directory. It returns false if there was nothing to serve.
+func (h *staticHandler) serveFile(rw http.ResponseWriter, req *http.Request, name string) bool {
+	info, err := fs.Stat(h.fsys, name)
+	if err != nil {
+		return false
+	}
+
+	if info.IsDir() {
+		name = path.Join(name, h.opts.Index)
+		info, err = fs.Stat(h.fsys, name)
+		if err != nil || info.IsDir() {
+			return false
+		}
+	}
+
+	served, servedInfo, coding := name, info, ""
+
+	if h.opts.Precompressed {
+		accept := req.Header.Get("Accept-Encoding")
+		for _, enc := range precompressedEncodings {
+			if encodingQuality(accept, enc.coding) <= 0 {
+				continue
+			}
+			if encInfo, err := fs.Stat(h.fsys, name+enc.ext); err == nil && !encInfo.IsDir() {
+				served, servedInfo, coding = name+enc.ext, encInfo, enc.coding
+				break
+			}
+		}
+	}
+
+	content, err := h.open(served)
+	if err != nil {
+		return false
+	}
+	if c, ok := content.(io.Closer); ok {
+		defer c.Close()
+	}
+
+	etag, err := h.etag(served, servedInfo, content)
+	if err != nil {
+		return false
+	}
+	rw.Header().Set("ETag", etag)
+
+	if h.opts.Precompressed {
+		rw.Header().Add("Vary", "Accept-Encoding")
+	}
+
+	if coding != "" {
+		rw.Header().Set("Content-Encoding", coding)
+
+		// the type comes from the original name, not the compressed content
+		ctype := mime.TypeByExtension(path.Ext(name))
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+		rw.Header().Set("Content-Type", ctype)
+	}
+
+	http.ServeContent(rw, req, name, info.ModTime(), content)
+	return true
+}
+
+// open returns the file as a ReadSeeker, reading it into memory if the file system doesn't support seeking
+func (h *staticHandler) open(name string) (io.ReadSeeker, error) {
+	f, err := h.fsys.Open(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if rs, ok := f.(io.ReadSeeker); ok {
+		return rs, nil
+	}
+
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(data), nil
+}
+
+// etag returns the entity tag of a file's contents, computing it only if the file has changed
+func (h *staticHandler) etag(name string, info fs.FileInfo, content io.ReadSeeker) (string, error) {
+	if cached, ok := h.etags.Load(name); ok {
+		c := cached.(*staticETag)
+		if c.modTime.Equal(info.ModTime()) && c.size == info.Size() {
+			return c.etag, nil
+		}
+	}
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, content); err != nil {
+		return "", err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return "", err
+	}
+
+	etag := `"` + hex.EncodeToString(hash.Sum(nil)[:16]) + `"`
+
+	h.etags.Store(name, &staticETag{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		etag:    etag,
+	})
+
+	return etag, nil
+}
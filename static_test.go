@@ -0,0 +1,160 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+var staticFS = fstest.MapFS{
+	"index.html":       {Data: []byte("root index"), ModTime: time.Unix(1500000000, 0)},
+	"app.js":           {Data: []byte("console.log('hi')")},
+	"app.js.gz":        {Data: []byte("gzipped js")},
+	"app.js.br":        {Data: []byte("brotli js")},
+	"docs/index.html":  {Data: []byte("docs index")},
+	"empty/readme.txt": {Data: []byte("readme")},
+	"images/a b.txt":   {Data: []byte("spaced")},
+	"images/logo.svg":  {Data: []byte("<svg/>")},
+}
+
+func staticRequest(s *ServeMux, path string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoute_Static(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/assets").Static(staticFS, nil)
+
+	tests := map[string]string{
+		"/assets":                  "root index",
+		"/assets/app.js":           "console.log('hi')",
+		"/assets/docs":             "docs index",
+		"/assets/images/a%20b.txt": "spaced",
+	}
+
+	for path, body := range tests {
+		rec := staticRequest(s, path, nil)
+		if rec.Code != http.StatusOK || rec.Body.String() != body {
+			t.Errorf("Wrong response for %s: %d %s", path, rec.Code, rec.Body.String())
+		}
+	}
+
+	if ct := staticRequest(s, "/assets/images/logo.svg", nil).Header().Get("Content-Type"); ct != "image/svg+xml" {
+		t.Error("Wrong content type", ct)
+	}
+}
+
+func TestRoute_StaticNotFound(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/assets").NotFound(dummyHandler("custom not found")).Static(staticFS, nil)
+
+	for _, path := range []string{"/assets/missing.js", "/assets/empty", "/assets/../secret"} {
+		rec := staticRequest(s, path, nil)
+		if rec.Body.String() != "custom not found" {
+			t.Errorf("Inherited not found handler not used for %s: %s", path, rec.Body.String())
+		}
+	}
+}
+
+func TestRoute_StaticWildcard(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/assets/*").Static(staticFS, nil)
+
+	rec := staticRequest(s, "/assets/docs/index.html", nil)
+	if rec.Body.String() != "docs index" {
+		t.Error("Wildcard route not served", rec.Body.String())
+	}
+}
+
+func TestRoute_StaticSPA(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Static(staticFS, &StaticOptions{SPA: true})
+
+	if rec := staticRequest(s, "/users/andrew", nil); rec.Body.String() != "root index" {
+		t.Error("SPA fallback not served", rec.Body.String())
+	}
+
+	if rec := staticRequest(s, "/missing.js", nil); rec.Code != http.StatusNotFound {
+		t.Error("Missing asset should not fall back", rec.Code)
+	}
+}
+
+func TestRoute_StaticConditional(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Static(staticFS, nil)
+
+	rec := staticRequest(s, "/", nil)
+
+	etag := rec.Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("No ETag set")
+	}
+
+	if rec.Header().Get("Last-Modified") != "Fri, 14 Jul 2017 02:40:00 GMT" {
+		t.Error("Wrong Last-Modified", rec.Header().Get("Last-Modified"))
+	}
+
+	rec = staticRequest(s, "/", http.Header{"If-None-Match": {etag}})
+	if rec.Code != http.StatusNotModified {
+		t.Error("If-None-Match not honoured", rec.Code)
+	}
+
+	rec = staticRequest(s, "/", http.Header{"If-Modified-Since": {"Fri, 14 Jul 2017 02:40:00 GMT"}})
+	if rec.Code != http.StatusNotModified {
+		t.Error("If-Modified-Since not honoured", rec.Code)
+	}
+}
+
+func TestRoute_StaticPrecompressed(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Static(staticFS, &StaticOptions{Precompressed: true})
+
+	rec := staticRequest(s, "/app.js", http.Header{"Accept-Encoding": {"gzip, br;q=0.5"}})
+	if rec.Body.String() != "brotli js" || rec.Header().Get("Content-Encoding") != "br" {
+		t.Error("Brotli variant not served", rec.Body.String())
+	}
+
+	if rec.Header().Get("Content-Type") != "text/javascript; charset=utf-8" {
+		t.Error("Wrong content type", rec.Header().Get("Content-Type"))
+	}
+
+	if rec.Header().Get("Vary") != "Accept-Encoding" {
+		t.Error("Missing Vary header")
+	}
+
+	brETag := rec.Header().Get("ETag")
+
+	rec = staticRequest(s, "/app.js", http.Header{"Accept-Encoding": {"gzip, br;q=0"}})
+	if rec.Body.String() != "gzipped js" || rec.Header().Get("Content-Encoding") != "gzip" {
+		t.Error("Gzip variant not served", rec.Body.String())
+	}
+
+	if rec.Header().Get("ETag") == brETag {
+		t.Error("Encodings share an ETag")
+	}
+
+	rec = staticRequest(s, "/app.js", nil)
+	if rec.Body.String() != "console.log('hi')" || rec.Header().Get("Content-Encoding") != "" {
+		t.Error("Uncompressed file not served", rec.Body.String())
+	}
+}
+
+func TestRoute_StaticMethods(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Static(staticFS, nil)
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/app.js", nil))
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Error("Static files accepted a POST", rec.Code)
+	}
+}
@@ -0,0 +1,146 @@
+package powermux
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// TimeoutOptions configures a route timeout. The zero value is a usable configuration.
+type TimeoutOptions struct {
+	// Status is the response code sent when the handler doesn't finish in time,
+	// http.StatusServiceUnavailable by default. http.StatusGatewayTimeout suits handlers waiting on upstreams.
+	Status int
+
+	// Handler writes the response when the handler doesn't finish in time, in place of the status code.
+	// It receives the request with its expired context.
+	Handler http.Handler
+}
+
+// routeTimeout is the timeout that applies to a route and those below it
+type routeTimeout struct {
+	duration time.Duration
+	status   int
+	handler  http.Handler
+}
+
+// Timeout limits how long the handlers of this route and every route below it may take to respond.
+//
+// The handler's request context is cancelled after d. If the handler hasn't returned by then, a 503
+// Service Unavailable response is sent, or whatever opts configures, and anything the handler writes
+// afterwards is discarded. Handler responses are buffered until the handler returns, so timeouts are
+// unsuitable for streaming routes.
+//
+// Routes below this one can set their own timeout, or disable it by passing a zero duration. Middleware
+// runs outside the timeout.
+func (r *Route) Timeout(d time.Duration, opts *TimeoutOptions) *Route {
+	t := &routeTimeout{
+		duration: d,
+		status:   http.StatusServiceUnavailable,
+	}
+	if opts != nil {
+		if opts.Status != 0 {
+			t.status = opts.Status
+		}
+		t.handler = opts.Handler
+	}
+
+	r.timeout = t
+	return r
+}
+
+// wrap returns the handler limited by the timeout
+func (t *routeTimeout) wrap(h http.Handler) http.Handler {
+	if t.duration <= 0 {
+		return h
+	}
+	return &timeoutHandler{
+		handler: h,
+		timeout: t,
+	}
+}
+
+// timeoutHandler runs a handler with a deadline, responding for it if it misses it
+type timeoutHandler struct {
+	handler http.Handler
+	timeout *routeTimeout
+}
+
+// ServeHTTP runs the handler in its own goroutine and waits for it to finish or time out
+func (h *timeoutHandler) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	ctx, cancel := context.WithTimeout(req.Context(), h.timeout.duration)
+	defer cancel()
+
+	// the handler may outlive this request, so it can't share the pooled execution
+	if ex := getRequestExecution(req); ex != nil {
+		ctx = context.WithValue(ctx, executionKey, ex.detach())
+	}
+	req = req.WithContext(ctx)
+
+	tw := &timeoutWriter{
+		responseBuffer: newResponseBuffer(),
+	}
+	done := make(chan struct{})
+	panicked := make(chan interface{}, 1)
+
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				panicked <- p
+			}
+		}()
+		h.handler.ServeHTTP(tw, req)
+		close(done)
+	}()
+
+	select {
+	case p := <-panicked:
+		panic(p)
+
+	case <-done:
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.writeTo(rw)
+
+	case <-ctx.Done():
+		tw.mu.Lock()
+		defer tw.mu.Unlock()
+		tw.timedOut = true
+
+		if h.timeout.handler != nil {
+			h.timeout.handler.ServeHTTP(rw, req)
+			return
+		}
+		http.Error(rw, http.StatusText(h.timeout.status), h.timeout.status)
+	}
+}
+
+// timeoutWriter buffers a response until the handler finishes
+type timeoutWriter struct {
+	*responseBuffer
+	mu       sync.Mutex
+	timedOut bool
+}
+
+// Write buffers the body, failing once the handler has timed out
+func (tw *timeoutWriter) Write(p []byte) (int, error) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return 0, http.ErrHandlerTimeout
+	}
+	return tw.responseBuffer.Write(p)
+}
+
+// WriteHeader records the status code of the response
+func (tw *timeoutWriter) WriteHeader(code int) {
+	tw.mu.Lock()
+	defer tw.mu.Unlock()
+
+	if tw.timedOut {
+		return
+	}
+	tw.responseBuffer.WriteHeader(code)
+}
@@ -0,0 +1,117 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// slowHandler responds after a delay unless the request is cancelled first
+func slowHandler(delay time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-time.After(delay):
+			w.Header().Set("X-Param", PathParam(r, "id"))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("done"))
+		case <-r.Context().Done():
+		}
+	}
+}
+
+func TestRoute_Timeout(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/slow/:id").Timeout(10*time.Millisecond, nil).Get(slowHandler(time.Second))
+	s.Route("/fast/:id").Timeout(time.Second, nil).Get(slowHandler(0))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/slow/1", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Error("Slow handler not timed out", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/fast/1", nil))
+	if rec.Code != http.StatusCreated || rec.Body.String() != "done" || rec.Header().Get("X-Param") != "1" {
+		t.Error("Fast handler response not passed on", rec.Code, rec.Body.String(), rec.Header())
+	}
+}
+
+func TestRoute_TimeoutOptions(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/gateway").Timeout(10*time.Millisecond, &TimeoutOptions{
+		Status: http.StatusGatewayTimeout,
+	}).Get(slowHandler(time.Second))
+	s.Route("/custom").Timeout(10*time.Millisecond, &TimeoutOptions{
+		Handler: dummyHandler("too slow"),
+	}).Get(slowHandler(time.Second))
+
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/gateway", nil))
+	if rec.Code != http.StatusGatewayTimeout {
+		t.Error("Wrong timeout status", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/custom", nil))
+	if rec.Body.String() != "too slow" {
+		t.Error("Custom timeout handler not used", rec.Body.String())
+	}
+}
+
+func TestRoute_TimeoutInheritance(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/api").Timeout(10*time.Millisecond, nil)
+	s.Route("/api/inherited").Get(slowHandler(time.Second))
+	s.Route("/api/longer").Timeout(time.Second, nil).Get(slowHandler(50 * time.Millisecond))
+	s.Route("/api/unlimited").Timeout(0, nil).Get(slowHandler(50 * time.Millisecond))
+
+	tests := map[string]int{
+		"/api/inherited": http.StatusServiceUnavailable,
+		"/api/longer":    http.StatusCreated,
+		"/api/unlimited": http.StatusCreated,
+	}
+
+	for path, code := range tests {
+		rec := httptest.NewRecorder()
+		s.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != code {
+			t.Errorf("Wrong status for %s: %d", path, rec.Code)
+		}
+	}
+
+	// handlers are only wrapped where a timeout applies
+	h := dummyHandler("unlimited")
+	s.Route("/other").Get(h)
+	if found, _ := s.Handler(httptest.NewRequest(http.MethodGet, "/other", nil)); found != h {
+		t.Error("Handler wrapped without a timeout")
+	}
+}
+
+func TestRoute_TimeoutDeadline(t *testing.T) {
+	s := NewServeMux()
+
+	var deadline time.Time
+	var ok bool
+	s.Route("/").Timeout(time.Minute, nil).GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		deadline, ok = r.Context().Deadline()
+	})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !ok || time.Until(deadline) < 50*time.Second {
+		t.Error("Deadline not applied to the request context", deadline)
+	}
+}
+
+func TestRoute_TimeoutPanic(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/").Timeout(time.Second, nil).Get(panicHandler("boom"))
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Panic not passed on to the serving goroutine")
+		}
+	}()
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+}
@@ -0,0 +1,172 @@
+package powermux
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"strings"
+)
+
+// SpanContext identifies a span across process boundaries, as described by the W3C Trace Context specification.
+type SpanContext struct {
+	TraceID    [16]byte
+	SpanID     [8]byte
+	Flags      byte
+	TraceState string
+}
+
+// IsValid returns whether both the trace and span IDs are set
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != [16]byte{} && sc.SpanID != [8]byte{}
+}
+
+// Sampled returns whether the sampled flag is set
+func (sc SpanContext) Sampled() bool {
+	return sc.Flags&0x01 != 0
+}
+
+// Traceparent formats the span context as a version 00 traceparent header value
+func (sc SpanContext) Traceparent() string {
+	return "00-" + hex.EncodeToString(sc.TraceID[:]) + "-" + hex.EncodeToString(sc.SpanID[:]) + "-" +
+		hex.EncodeToString([]byte{sc.Flags})
+}
+
+// ParseTraceparent parses a traceparent header value. The returned bool is false if the value is malformed
+// or doesn't identify a valid span.
+func ParseTraceparent(value string) (SpanContext, bool) {
+	var sc SpanContext
+
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return sc, false
+	}
+
+	version, err := hex.DecodeString(parts[0])
+	if err != nil || len(version) != 1 || version[0] == 0xff {
+		return sc, false
+	}
+
+	// version 00 has exactly four fields, future versions may add more
+	if version[0] == 0 && len(parts) != 4 {
+		return sc, false
+	}
+
+	if len(parts[1]) != 32 || len(parts[2]) != 16 || len(parts[3]) != 2 {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.TraceID[:], []byte(parts[1])); err != nil {
+		return sc, false
+	}
+	if _, err := hex.Decode(sc.SpanID[:], []byte(parts[2])); err != nil {
+		return sc, false
+	}
+	flags, err := hex.DecodeString(parts[3])
+	if err != nil {
+		return sc, false
+	}
+	sc.Flags = flags[0]
+
+	return sc, sc.IsValid()
+}
+
+// Span is a single traced operation.
+type Span interface {
+	// SetAttribute records a key/value pair on the span
+	SetAttribute(key string, value interface{})
+	// SpanContext returns the identity of the span for propagation
+	SpanContext() SpanContext
+	// End completes the span
+	End()
+}
+
+// Tracer starts spans. It is intended as a thin adapter over a tracing library such as OpenTelemetry.
+type Tracer interface {
+	// Start begins a span with the given name. The parent is the span context received from the caller, and
+	// is invalid if there was none. The returned context should carry the new span.
+	Start(ctx context.Context, name string, parent SpanContext) (context.Context, Span)
+}
+
+type tracingCtxKeyType string
+
+var tracingCtxKey = tracingCtxKeyType("span")
+
+// SpanFromContext returns the span started by the Tracing middleware, or nil if there is none.
+func SpanFromContext(ctx context.Context) Span {
+	span, _ := ctx.Value(tracingCtxKey).(Span)
+	return span
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers for an outgoing request from the span
+// in ctx, so downstream services continue the trace.
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return
+	}
+
+	sc := span.SpanContext()
+	if !sc.IsValid() {
+		return
+	}
+
+	header.Set("traceparent", sc.Traceparent())
+	if sc.TraceState != "" {
+		header.Set("tracestate", sc.TraceState)
+	} else {
+		header.Del("tracestate")
+	}
+}
+
+// Tracing is a middleware that starts a span for each request.
+//
+// Spans are named by method and route pattern, such as "GET /users/:id", rather than the raw path.
+// Path parameters are recorded as attributes, and an incoming traceparent header is used as the parent span.
+type Tracing struct {
+	// Tracer starts the spans
+	Tracer Tracer
+}
+
+// ServeHTTPMiddleware traces the rest of the chain.
+func (m *Tracing) ServeHTTPMiddleware(rw http.ResponseWriter, req *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	parent, _ := ParseTraceparent(req.Header.Get("traceparent"))
+	if parent.IsValid() {
+		parent.TraceState = req.Header.Get("tracestate")
+	}
+
+	pattern := RequestPath(req)
+	name := req.Method
+	if pattern != "" {
+		name = req.Method + " " + pattern
+	}
+
+	ctx, span := m.Tracer.Start(req.Context(), name, parent)
+	ctx = context.WithValue(ctx, tracingCtxKey, span)
+	defer span.End()
+
+	span.SetAttribute("http.request.method", req.Method)
+	span.SetAttribute("url.path", req.URL.Path)
+	if pattern != "" {
+		span.SetAttribute("http.route", pattern)
+	}
+	if req.Host != "" {
+		span.SetAttribute("server.address", req.Host)
+	}
+	for name, value := range PathParams(req) {
+		span.SetAttribute("http.route.param."+name, value)
+	}
+
+	w := newResponseWriter(rw)
+
+	// record the status even if the handler panics
+	completed := false
+	defer func() {
+		status := w.Status()
+		if !completed && !w.Written() {
+			status = http.StatusInternalServerError
+		}
+		span.SetAttribute("http.response.status_code", status)
+	}()
+
+	next(w, req.WithContext(ctx))
+	completed = true
+}
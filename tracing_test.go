@@ -0,0 +1,171 @@
+package powermux
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// recordedSpan is a span kept in memory for inspection
+type recordedSpan struct {
+	name       string
+	parent     SpanContext
+	sc         SpanContext
+	attributes map[string]interface{}
+	ended      bool
+}
+
+func (s *recordedSpan) SetAttribute(key string, value interface{}) {
+	s.attributes[key] = value
+}
+
+func (s *recordedSpan) SpanContext() SpanContext {
+	return s.sc
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+// recordingTracer keeps every span it starts
+type recordingTracer struct {
+	lock  sync.Mutex
+	spans []*recordedSpan
+}
+
+func (t *recordingTracer) Start(ctx context.Context, name string, parent SpanContext) (context.Context, Span) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	span := &recordedSpan{
+		name:       name,
+		parent:     parent,
+		attributes: make(map[string]interface{}),
+	}
+
+	// continue the parent trace or start a new one
+	if parent.IsValid() {
+		span.sc.TraceID = parent.TraceID
+		span.sc.TraceState = parent.TraceState
+	} else {
+		span.sc.TraceID[0] = 0xaa
+	}
+	span.sc.SpanID[7] = byte(len(t.spans) + 1)
+	span.sc.Flags = 1
+
+	t.spans = append(t.spans, span)
+	return ctx, span
+}
+
+func TestTracing_Span(t *testing.T) {
+	s := NewServeMux()
+	tracer := &recordingTracer{}
+
+	var outgoing http.Header
+
+	s.Route("/").Middleware(&Tracing{Tracer: tracer})
+	s.Route("/users/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		outgoing = make(http.Header)
+		InjectTraceContext(r.Context(), outgoing)
+		w.WriteHeader(http.StatusAccepted)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/users/andrew", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	req.Header.Set("tracestate", "vendor=value")
+
+	s.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(tracer.spans) != 1 {
+		t.Fatal("Wrong number of spans", len(tracer.spans))
+	}
+	span := tracer.spans[0]
+
+	if span.name != "GET /users/:id" {
+		t.Error("Wrong span name", span.name)
+	}
+
+	if !span.ended {
+		t.Error("Span not ended")
+	}
+
+	if span.attributes["http.route.param.id"] != "andrew" {
+		t.Error("Path param not recorded", span.attributes)
+	}
+
+	if span.attributes["http.route"] != "/users/:id" {
+		t.Error("Route not recorded", span.attributes)
+	}
+
+	if span.attributes["http.response.status_code"] != http.StatusAccepted {
+		t.Error("Status not recorded", span.attributes["http.response.status_code"])
+	}
+
+	if span.parent.Traceparent() != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Error("Wrong parent", span.parent.Traceparent())
+	}
+
+	if outgoing.Get("traceparent") != "00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000001-01" {
+		t.Error("Wrong outgoing traceparent", outgoing.Get("traceparent"))
+	}
+
+	if outgoing.Get("tracestate") != "vendor=value" {
+		t.Error("Trace state not propagated", outgoing.Get("tracestate"))
+	}
+}
+
+func TestTracing_NotFound(t *testing.T) {
+	s := NewServeMux()
+	tracer := &recordingTracer{}
+
+	s.Route("/").Middleware(&Tracing{Tracer: tracer})
+
+	s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/nothing/here", nil))
+
+	if len(tracer.spans) != 1 {
+		t.Fatal("Wrong number of spans", len(tracer.spans))
+	}
+
+	if tracer.spans[0].name != "GET" {
+		t.Error("Unmatched requests should be named by method only", tracer.spans[0].name)
+	}
+
+	if tracer.spans[0].parent.IsValid() {
+		t.Error("Parent set without a traceparent header")
+	}
+}
+
+func TestParseTraceparent(t *testing.T) {
+	valid := []string{
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-extra",
+	}
+	invalid := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e473z-00f067aa0ba902b7-01",
+	}
+
+	for _, v := range valid {
+		if _, ok := ParseTraceparent(v); !ok {
+			t.Error("Valid traceparent rejected", v)
+		}
+	}
+
+	for _, v := range invalid {
+		if _, ok := ParseTraceparent(v); ok {
+			t.Error("Invalid traceparent accepted", v)
+		}
+	}
+
+	sc, _ := ParseTraceparent(valid[0])
+	if !sc.Sampled() || sc.Traceparent() != valid[0] {
+		t.Error("Traceparent didn't round trip", sc.Traceparent())
+	}
+}
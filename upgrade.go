@@ -0,0 +1,108 @@
+package powermux
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// Upgrade registers a handler for GET requests asking to switch to protocol, such as "websocket", with the
+// Connection: Upgrade and Upgrade headers. It can be registered alongside a GET handler for the same route, which
+// continues to serve ordinary requests. If there is none, ordinary requests get a 426 Upgrade Required response.
+//
+// Upgrade requests run through the same middleware as any other, but the handler can always hijack the
+// connection, even if a middleware wrapped the response writer in one that doesn't support it. Timeouts and
+// caching policies don't apply to upgrade handlers.
+//
+//	mux.Route("/chat").
+//		Get(chatPage).
+//		Upgrade("websocket", chatSocket)
+func (r *Route) Upgrade(protocol string, handler http.Handler) *Route {
+	r.handle(http.MethodGet, handler)
+	r.variant("Upgrade").upgrade = strings.ToLower(protocol)
+	return r
+}
+
+// UpgradeFunc registers a plain function as an upgrade handler.
+func (r *Route) UpgradeFunc(protocol string, f http.HandlerFunc) *Route {
+	return r.Upgrade(protocol, http.HandlerFunc(f))
+}
+
+// upgradesTo returns whether the request asks to switch to the protocol
+func upgradesTo(req *http.Request, protocol string) bool {
+	if req == nil || !headerHasToken(req.Header, "Connection", "upgrade") {
+		return false
+	}
+
+	for _, v := range req.Header.Values("Upgrade") {
+		for _, offer := range strings.Split(v, ",") {
+			offer = strings.ToLower(strings.TrimSpace(offer))
+			// match any version unless one was asked for
+			if !strings.Contains(protocol, "/") {
+				offer, _, _ = strings.Cut(offer, "/")
+			}
+			if offer == protocol {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// headerHasToken returns whether any value of a comma separated header contains the token, ignoring case
+func headerHasToken(header http.Header, name, token string) bool {
+	for _, v := range header.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeRequiredHandler tells the client which protocols it must switch to
+func upgradeRequiredHandler(protocols []string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Upgrade", strings.Join(protocols, ", "))
+		w.Header().Set("Connection", "Upgrade")
+		http.Error(w, http.StatusText(http.StatusUpgradeRequired), http.StatusUpgradeRequired)
+	})
+}
+
+// hijackWriter restores http.Hijacker to a response writer that lost it
+type hijackWriter struct {
+	http.ResponseWriter
+	hijacker http.Hijacker
+}
+
+// Hijack takes over the connection through the writer the server gave the mux
+func (w *hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return w.hijacker.Hijack()
+}
+
+// Unwrap returns the wrapped writer for use by http.ResponseController
+func (w *hijackWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// hijackable returns a writer that can hijack the connection, falling back on the writer the mux was given
+// if middleware hid its Hijacker
+func hijackable(w http.ResponseWriter, req *http.Request) http.ResponseWriter {
+	if _, ok := w.(http.Hijacker); ok {
+		return w
+	}
+
+	ex := getRequestExecution(req)
+	if ex == nil {
+		return w
+	}
+	if h, ok := ex.writer.(http.Hijacker); ok {
+		return &hijackWriter{
+			ResponseWriter: w,
+			hijacker:       h,
+		}
+	}
+	return w
+}
@@ -0,0 +1,141 @@
+package powermux
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func upgradeRequest(s *ServeMux, method, path, connection, upgrade string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if connection != "" {
+		req.Header.Set("Connection", connection)
+	}
+	if upgrade != "" {
+		req.Header.Set("Upgrade", upgrade)
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRoute_Upgrade(t *testing.T) {
+	s := NewServeMux()
+	s.Route("/chat").
+		Get(dummyHandler("page")).
+		Upgrade("websocket", dummyHandler("socket"))
+	s.Route("/socket").
+		UpgradeFunc("websocket", func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, "socket")
+		})
+
+	tests := []struct {
+		method     string
+		path       string
+		connection string
+		upgrade    string
+		code       int
+		body       string
+	}{
+		{http.MethodGet, "/chat", "", "", http.StatusOK, "page"},
+		{http.MethodGet, "/chat", "Upgrade", "websocket", http.StatusOK, "socket"},
+		{http.MethodGet, "/chat", "keep-alive, upgrade", "WebSocket/13", http.StatusOK, "socket"},
+		{http.MethodGet, "/chat", "", "websocket", http.StatusOK, "page"},
+		{http.MethodGet, "/chat", "Upgrade", "h2c", http.StatusOK, "page"},
+		{http.MethodGet, "/socket", "Upgrade", "foo, websocket", http.StatusOK, "socket"},
+		{http.MethodGet, "/socket", "", "", http.StatusUpgradeRequired, ""},
+		{http.MethodGet, "/socket", "Upgrade", "h2c", http.StatusUpgradeRequired, ""},
+		{http.MethodPost, "/socket", "Upgrade", "websocket", http.StatusMethodNotAllowed, ""},
+	}
+
+	for _, test := range tests {
+		rec := upgradeRequest(s, test.method, test.path, test.connection, test.upgrade)
+
+		if rec.Code != test.code {
+			t.Error("Wrong status for", test.path, test.connection, test.upgrade, rec.Code)
+		}
+		if test.body != "" && rec.Body.String() != test.body {
+			t.Error("Wrong handler for", test.path, test.connection, test.upgrade, rec.Body.String())
+		}
+		if rec.Code == http.StatusUpgradeRequired && rec.Header().Get("Upgrade") != "websocket" {
+			t.Error("Required protocol not sent", rec.Header())
+		}
+	}
+}
+
+// hidingMiddleware wraps the response writer in one that only implements http.ResponseWriter
+type hidingMiddleware struct{}
+
+func (hidingMiddleware) ServeHTTPMiddleware(w http.ResponseWriter, r *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	next(struct{ http.ResponseWriter }{w}, r)
+}
+
+func TestRoute_UpgradeHijack(t *testing.T) {
+	var plainHijacker bool
+
+	s := NewServeMux()
+	s.Route("/").
+		Middleware(hidingMiddleware{}).
+		Timeout(10*time.Millisecond, nil).
+		Cache(&CachePolicy{MaxAge: time.Minute})
+	s.Route("/echo").
+		GetFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, plainHijacker = w.(http.Hijacker)
+		}).
+		UpgradeFunc("echo", func(w http.ResponseWriter, r *http.Request) {
+			// outlive the route's timeout
+			time.Sleep(30 * time.Millisecond)
+
+			conn, buf, err := w.(http.Hijacker).Hijack()
+			if err != nil {
+				t.Error("Hijack failed", err)
+				return
+			}
+			defer conn.Close()
+
+			buf.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			buf.Flush()
+
+			line, _ := buf.ReadString('\n')
+			buf.WriteString(line)
+			buf.Flush()
+		})
+
+	server := httptest.NewServer(s)
+	defer server.Close()
+
+	if _, err := http.Get(server.URL + "/echo"); err != nil {
+		t.Fatal(err)
+	}
+	if plainHijacker {
+		t.Error("Hijacker restored for an ordinary handler")
+	}
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	io.WriteString(conn, "GET /echo HTTP/1.1\r\nHost: example.com\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	r := bufio.NewReader(conn)
+
+	resp, err := http.ReadResponse(r, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatal("Connection not upgraded", resp.StatusCode)
+	}
+
+	io.WriteString(conn, "hello\n")
+	line, _ := r.ReadString('\n')
+	if strings.TrimSpace(line) != "hello" {
+		t.Error("Wrong echo", line)
+	}
+}
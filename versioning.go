@@ -0,0 +1,268 @@
+package powermux
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionFallback decides how requests that don't ask for a version are handled.
+type VersionFallback int
+
+const (
+	// FallbackLatest serves requests without a version with the latest version.
+	FallbackLatest VersionFallback = iota
+	// FallbackNotFound serves requests without a version with the not found handler.
+	FallbackNotFound
+)
+
+// Version is a set of routes for one version of an API.
+type Version struct {
+	name string
+	mux  *ServeMux
+	// the version before this one, if any
+	previous *Version
+	// set if the version is deprecated
+	deprecation time.Time
+	sunset      time.Time
+	link        string
+}
+
+// Versions routes requests to one of several versions of an API.
+//
+// The version is taken from, in order of precedence, a path segment such as "/v2/users", a vendor media type
+// in the Accept header such as "application/vnd.example.v2+json", or a custom header. Each can be enabled
+// independently. Versions is an http.Handler intended to be mounted with Route.Mount, so the route patterns of
+// each version are combined with the route it is mounted on.
+type Versions struct {
+	// PathPrefix selects the version from the first path segment, "v" followed by the version name.
+	PathPrefix bool
+
+	// MediaType is a vendor media type, such as "application/vnd.example", which selects the version from
+	// Accept headers like "application/vnd.example.v2+json". The vendor type is replaced in the Accept header
+	// seen by handlers with its suffix type, such as "application/json", so Produces works as usual.
+	MediaType string
+
+	// Header is the name of a request header containing the version name.
+	Header string
+
+	// Fallback decides how requests that don't ask for a version are handled.
+	Fallback VersionFallback
+
+	// Inherit serves routes that aren't found in a version with the version registered before it,
+	// so each version only needs to declare the routes that changed.
+	Inherit bool
+
+	versions map[string]*Version
+	latest   *Version
+}
+
+type versionCtxKeyType string
+
+var versionCtxKey = versionCtxKeyType("version")
+
+// APIVersion returns the name of the API version serving the request, or an empty string if it wasn't
+// routed by Versions.
+func APIVersion(req *http.Request) string {
+	v, _ := req.Context().Value(versionCtxKey).(string)
+	return v
+}
+
+// Version returns the version with the given name, such as "2", creating it if necessary.
+// Versions are ordered by when they were first created, the last being the latest.
+func (vs *Versions) Version(name string) *Version {
+	name = strings.TrimPrefix(name, "v")
+
+	if v, ok := vs.versions[name]; ok {
+		return v
+	}
+
+	if vs.versions == nil {
+		vs.versions = make(map[string]*Version)
+	}
+
+	v := &Version{
+		name:     name,
+		mux:      NewServeMux(),
+		previous: vs.latest,
+	}
+	vs.versions[name] = v
+	vs.latest = v
+	return v
+}
+
+// Route returns the route for the given pattern within this version
+func (v *Version) Route(path string) *Route {
+	return v.mux.Route(path)
+}
+
+// Mux returns the ServeMux holding this version's routes
+func (v *Version) Mux() *ServeMux {
+	return v.mux
+}
+
+// Deprecate marks the version as deprecated since the given time. Responses from a deprecated version carry
+// a Deprecation header, and a Link header to the given documentation if it isn't empty.
+func (v *Version) Deprecate(since time.Time, link string) *Version {
+	v.deprecation = since
+	v.link = link
+	return v
+}
+
+// Sunset sets the time after which the version is expected to stop responding, which is sent in a Sunset header.
+func (v *Version) Sunset(at time.Time) *Version {
+	v.sunset = at
+	return v
+}
+
+// setHeaders adds the lifecycle headers of the version to a response
+func (v *Version) setHeaders(h http.Header) {
+	if !v.deprecation.IsZero() {
+		h.Set("Deprecation", "@"+strconv.FormatInt(v.deprecation.Unix(), 10))
+		if v.link != "" {
+			h.Add("Link", "<"+v.link+">; rel=\"deprecation\"")
+		}
+	}
+	if !v.sunset.IsZero() {
+		h.Set("Sunset", v.sunset.UTC().Format(http.TimeFormat))
+	}
+}
+
+// ServeHTTP routes the request to the version it asks for
+func (vs *Versions) ServeHTTP(rw http.ResponseWriter, req *http.Request) {
+	mount := getMountPoint(req)
+	if mount == nil {
+		mount = &mountPoint{
+			params: PathParams(req),
+		}
+	}
+
+	name, depth, requested := vs.requestedVersion(rw, req)
+
+	var v *Version
+	if requested {
+		v = vs.versions[name]
+	} else if vs.Fallback == FallbackLatest {
+		v = vs.latest
+	}
+
+	if v == nil {
+		if ex := getRequestExecution(req); ex != nil && ex.notFound != nil {
+			ex.notFound.ServeHTTP(rw, req)
+			return
+		}
+		http.NotFound(rw, req)
+		return
+	}
+
+	// the path version is part of the pattern
+	if depth > 0 {
+		mount = &mountPoint{
+			prefix: mount.prefix + "/v" + v.name,
+			params: mount.params,
+		}
+	}
+
+	req = req.WithContext(context.WithValue(req.Context(), versionCtxKey, v.name))
+	if vs.MediaType != "" {
+		req = vs.rewriteAccept(req)
+	}
+
+	// the lifecycle of the version asked for applies even to inherited routes
+	v.setHeaders(rw.Header())
+	v = vs.inherited(v, req, depth)
+
+	serveMounted(v.mux, rw, req, depth, mount)
+}
+
+// requestedVersion returns the name of the version the request asks for, and the number of path segments
+// used to ask for it. It marks the response as varying on any header that was checked.
+func (vs *Versions) requestedVersion(rw http.ResponseWriter, req *http.Request) (string, int, bool) {
+	if vs.PathPrefix {
+		segment := strings.TrimPrefix(trimSegments(req.URL.EscapedPath(), 0), "/")
+		if i := strings.IndexByte(segment, '/'); i >= 0 {
+			segment = segment[:i]
+		}
+		if strings.HasPrefix(segment, "v") {
+			if _, ok := vs.versions[segment[1:]]; ok {
+				return segment[1:], 1, true
+			}
+		}
+	}
+
+	if vs.MediaType != "" {
+		rw.Header().Add("Vary", "Accept")
+		prefix := strings.ToLower(vs.MediaType) + ".v"
+		for _, qv := range parseQualityList(req.Header.Get("Accept")) {
+			if qv.q > 0 && strings.HasPrefix(qv.value, prefix) {
+				name, _, _ := strings.Cut(qv.value[len(prefix):], "+")
+				return name, 0, true
+			}
+		}
+	}
+
+	if vs.Header != "" {
+		rw.Header().Add("Vary", vs.Header)
+		if name := req.Header.Get(vs.Header); name != "" {
+			return strings.TrimPrefix(name, "v"), 0, true
+		}
+	}
+
+	return "", 0, false
+}
+
+// rewriteAccept replaces vendor media types in the Accept header with their suffix types
+func (vs *Versions) rewriteAccept(req *http.Request) *http.Request {
+	accept := req.Header.Get("Accept")
+	prefix := strings.ToLower(vs.MediaType) + ".v"
+	if !strings.Contains(strings.ToLower(accept), prefix) {
+		return req
+	}
+
+	entries := strings.Split(accept, ",")
+	for i, entry := range entries {
+		entries[i] = strings.TrimSpace(entry)
+		mediaType, params, _ := strings.Cut(entries[i], ";")
+		if !strings.HasPrefix(strings.ToLower(mediaType), prefix) {
+			continue
+		}
+
+		suffix := "*"
+		if _, s, ok := strings.Cut(mediaType, "+"); ok {
+			suffix = s
+		}
+
+		entries[i] = "application/" + suffix
+		if params != "" {
+			entries[i] += ";" + params
+		}
+	}
+
+	r2 := req.Clone(req.Context())
+	r2.Header.Set("Accept", strings.Join(entries, ", "))
+	return r2
+}
+
+// inherited returns the version that has a route for the request, searching earlier versions if enabled
+func (vs *Versions) inherited(v *Version, req *http.Request, depth int) *Version {
+	if !vs.Inherit {
+		return v
+	}
+
+	// route against the path the versions will see
+	probe := req.Clone(req.Context())
+	probe.URL.Path = trimSegments(req.URL.Path, depth)
+	probe.URL.RawPath = ""
+
+	for candidate := v; candidate != nil; candidate = candidate.previous {
+		// routes with no handler still match a pattern, so check what would serve the request
+		ex := newExecution()
+		candidate.mux.getAll(probe, ex)
+		if ex.kind != HandlerNotFound {
+			return candidate
+		}
+	}
+	return v
+}
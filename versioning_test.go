@@ -0,0 +1,161 @@
+package powermux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func versionedMux(vs *Versions) *ServeMux {
+	v1 := vs.Version("1")
+	v1.Route("/users/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v1 " + PathParam(r, "id") + " " + RequestPath(r)))
+	})
+	v1.Route("/legacy").Get(dummyHandler("v1 legacy"))
+
+	v2 := vs.Version("v2")
+	v2.Route("/users/:id").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("v2 " + PathParam(r, "id") + " " + RequestPath(r) + " " + APIVersion(r)))
+	})
+
+	s := NewServeMux()
+	s.Route("/api").Mount(vs)
+	return s
+}
+
+func versionRequest(s *ServeMux, target string, header http.Header) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestVersions_PathPrefix(t *testing.T) {
+	s := versionedMux(&Versions{PathPrefix: true})
+
+	tests := map[string]string{
+		"/api/v1/users/andrew": "v1 andrew /api/v1/users/:id",
+		"/api/v2/users/andrew": "v2 andrew /api/v2/users/:id 2",
+		"/api/users/andrew":    "v2 andrew /api/users/:id 2",
+	}
+
+	for target, body := range tests {
+		if rec := versionRequest(s, target, nil); rec.Body.String() != body {
+			t.Errorf("Wrong response for %s: %s", target, rec.Body.String())
+		}
+	}
+}
+
+func TestVersions_MediaType(t *testing.T) {
+	vs := &Versions{MediaType: "application/vnd.example"}
+	s := versionedMux(vs)
+
+	var accept string
+	vs.Version("1").Route("/accept").GetFunc(func(w http.ResponseWriter, r *http.Request) {
+		accept = r.Header.Get("Accept")
+	}).Produces("application/json")
+
+	rec := versionRequest(s, "/api/users/andrew", http.Header{"Accept": {"application/vnd.example.v1+json"}})
+	if rec.Body.String() != "v1 andrew /api/users/:id" {
+		t.Error("Wrong version served", rec.Body.String())
+	}
+
+	if rec.Header().Get("Vary") != "Accept" {
+		t.Error("Vary not set", rec.Header().Get("Vary"))
+	}
+
+	rec = versionRequest(s, "/api/accept", http.Header{"Accept": {"application/vnd.example.v1+json;q=0.9, text/html"}})
+	if rec.Code != http.StatusOK {
+		t.Error("Produces didn't match the rewritten Accept header", rec.Code)
+	}
+	if accept != "application/json;q=0.9, text/html" {
+		t.Error("Accept header not rewritten", accept)
+	}
+}
+
+func TestVersions_Header(t *testing.T) {
+	s := versionedMux(&Versions{Header: "X-Api-Version"})
+
+	rec := versionRequest(s, "/api/users/andrew", http.Header{"X-Api-Version": {"1"}})
+	if rec.Body.String() != "v1 andrew /api/users/:id" {
+		t.Error("Wrong version served", rec.Body.String())
+	}
+
+	rec = versionRequest(s, "/api/users/andrew", http.Header{"X-Api-Version": {"v2"}})
+	if rec.Body.String() != "v2 andrew /api/users/:id 2" {
+		t.Error("Wrong version served", rec.Body.String())
+	}
+
+	rec = versionRequest(s, "/api/users/andrew", http.Header{"X-Api-Version": {"9"}})
+	if rec.Code != http.StatusNotFound {
+		t.Error("Unknown version served", rec.Code)
+	}
+}
+
+func TestVersions_FallbackNotFound(t *testing.T) {
+	s := versionedMux(&Versions{Header: "X-Api-Version", Fallback: FallbackNotFound})
+	s.Route("/api").NotFound(dummyHandler("custom not found"))
+
+	rec := versionRequest(s, "/api/users/andrew", nil)
+	if rec.Body.String() != "custom not found" {
+		t.Error("Request without a version not rejected", rec.Body.String())
+	}
+}
+
+func TestVersions_Inherit(t *testing.T) {
+	s := versionedMux(&Versions{PathPrefix: true, Inherit: true})
+
+	if rec := versionRequest(s, "/api/v2/legacy", nil); rec.Body.String() != "v1 legacy" {
+		t.Error("Route not inherited", rec.Body.String())
+	}
+
+	if rec := versionRequest(s, "/api/v2/users/andrew", nil); rec.Body.String() != "v2 andrew /api/v2/users/:id 2" {
+		t.Error("Own route not preferred", rec.Body.String())
+	}
+
+	s = versionedMux(&Versions{PathPrefix: true})
+	if rec := versionRequest(s, "/api/v2/legacy", nil); rec.Code != http.StatusNotFound {
+		t.Error("Route inherited without being enabled", rec.Code)
+	}
+}
+
+func TestVersions_InheritAboveOwnRoute(t *testing.T) {
+	vs := &Versions{PathPrefix: true, Inherit: true}
+	s := versionedMux(vs)
+	vs.Version("1").Route("/users").Get(dummyHandler("v1 users"))
+
+	// v2 has a route below /users, but no handler for it
+	if rec := versionRequest(s, "/api/v2/users", nil); rec.Body.String() != "v1 users" {
+		t.Error("Route not inherited", rec.Code, rec.Body.String())
+	}
+}
+
+func TestVersions_Lifecycle(t *testing.T) {
+	vs := &Versions{PathPrefix: true}
+	s := versionedMux(vs)
+
+	vs.Version("1").
+		Deprecate(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), "https://example.com/migrate").
+		Sunset(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	rec := versionRequest(s, "/api/v1/users/andrew", nil)
+
+	if rec.Header().Get("Deprecation") != "@1704067200" {
+		t.Error("Wrong Deprecation header", rec.Header().Get("Deprecation"))
+	}
+	if rec.Header().Get("Sunset") != "Wed, 01 Jan 2025 00:00:00 GMT" {
+		t.Error("Wrong Sunset header", rec.Header().Get("Sunset"))
+	}
+	if rec.Header().Get("Link") != `<https://example.com/migrate>; rel="deprecation"` {
+		t.Error("Wrong Link header", rec.Header().Get("Link"))
+	}
+
+	rec = versionRequest(s, "/api/v2/users/andrew", nil)
+	if rec.Header().Get("Deprecation") != "" || rec.Header().Get("Sunset") != "" {
+		t.Error("Lifecycle headers set on current version")
+	}
+}